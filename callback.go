@@ -0,0 +1,52 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrCallbackAbandoned is returned when a callback did not return after its context was done,
+// e.g. on CallbackTimeout or when the manager shuts down.
+// The callback keeps running in the background and the resource is retried.
+var ErrCallbackAbandoned = errors.New("callback abandoned")
+
+// callbackAbandonGrace is how long a callback may take to return once its context is done.
+const callbackAbandonGrace = time.Second
+
+// runCallback calls fn for obj, which may be nil, with a duty context derived from ctx, bounded by CallbackTimeout.
+// It stops waiting for fn once the context is done, so a hung callback does not block a worker.
+func (r *Reconciler[T, PT]) runCallback(ctx gocontext.Context, obj client.Object, fn func(context.Context) error) error {
+	if r.CallbackTimeout > 0 {
+		var cancel gocontext.CancelFunc
+		ctx, cancel = gocontext.WithTimeout(ctx, r.CallbackTimeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("callback panicked: %v", p)
+			}
+		}()
+		done <- fn(r.callbackContext(ctx, obj))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// give callbacks that honor the cancellation a chance to return their own error
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(callbackAbandonGrace):
+			return fmt.Errorf("%w: %w", ErrCallbackAbandoned, ctx.Err())
+		}
+	}
+}
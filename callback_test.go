@@ -0,0 +1,95 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/context"
+)
+
+func TestRunCallbackTimeout(t *testing.T) {
+	r, _ := newTestReconciler(t)
+	r.CallbackTimeout = 10 * time.Millisecond
+
+	err := r.runCallback(gocontext.Background(), nil, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, gocontext.DeadlineExceeded) || errors.Is(err, ErrCallbackAbandoned) {
+		t.Errorf("expected the callback to return the deadline error, got %v", err)
+	}
+}
+
+func TestRunCallbackAbandoned(t *testing.T) {
+	r, _ := newTestReconciler(t)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	err := r.runCallback(ctx, nil, func(context.Context) error {
+		<-release // ignores its context, like a hung query
+		return nil
+	})
+	if !errors.Is(err, ErrCallbackAbandoned) || !errors.Is(err, gocontext.Canceled) {
+		t.Errorf("expected the callback to be abandoned, got %v", err)
+	}
+}
+
+func TestRunCallbackKeepsDutyContextValues(t *testing.T) {
+	r, _ := newTestReconciler(t)
+	r.DutyContext = r.DutyContext.WithValue("tenant", "acme")
+
+	err := r.runCallback(gocontext.Background(), nil, func(ctx context.Context) error {
+		if v := ctx.Value("tenant"); v != "acme" {
+			t.Errorf("expected the duty context values to be kept, got %v", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRunCallbackPanic(t *testing.T) {
+	r, _ := newTestReconciler(t)
+
+	err := r.runCallback(gocontext.Background(), nil, func(context.Context) error {
+		panic("boom")
+	})
+	if err == nil || err.Error() != "callback panicked: boom" {
+		t.Errorf("expected the panic to be returned as an error, got %v", err)
+	}
+}
+
+func TestCallbackContextCarriesObjectIdentity(t *testing.T) {
+	obj := newTestResource("identified")
+	r, _ := newTestReconciler(t, obj)
+	r.DutyContext = r.DutyContext.WithLoggingValues("tenant", "acme")
+
+	var id ObjectIdentity
+	var values map[string]any
+	r.OnUpsertFunc = func(ctx context.Context, _ *testResource) error {
+		id, _ = ObjectIdentityFromContext(ctx)
+		values = ctx.GetLoggingContext()
+		return nil
+	}
+
+	if _, _, err := reconcileTestResource(t, r, "identified"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if id.GVK != r.gvk || id.Namespace != "default" || id.Name != "identified" || id.UID != obj.UID || id.Generation != obj.Generation {
+		t.Errorf("unexpected object identity %+v", id)
+	}
+	if values["uid"] != string(obj.UID) || values["tenant"] != "acme" {
+		t.Errorf("expected the identity in the logging values, got %v", values)
+	}
+	if _, ok := r.DutyContext.GetLoggingContext()["uid"]; ok {
+		t.Errorf("expected the logging values of the reconciler's duty context to be left alone")
+	}
+}
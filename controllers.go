@@ -0,0 +1,113 @@
+package kopper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/duty/context"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Controllers sets up the reconcilers of several CRDs on one manager, with shared defaults.
+type Controllers struct {
+	// FinalizerPrefix derives the finalizer of each CRD as <FinalizerPrefix>/<lowercase kind>,
+	// e.g. "mission-control.flanksource.com/playbook".
+	FinalizerPrefix string
+
+	// EventRecorderName is the name events of every CRD are reported by.
+	// Defaults to the finalizer of each CRD.
+	EventRecorderName string
+
+	// RequeuePolicy is used by every reconciler that does not set its own.
+	RequeuePolicy RequeuePolicy
+
+	// Options are applied to every reconciler, before the options it was registered with.
+	// Typed options, e.g. WithOnUpsert, belong to Register: here they fail in Setup
+	// for every reconciler of another type.
+	Options []SharedOption
+
+	registrations []controllerRegistration
+}
+
+type controllerRegistration struct {
+	obj   client.Object
+	setup func(context.Context, ctrl.Manager, []SharedOption) error
+}
+
+// Registration gives access to a reconciler registered with Controllers,
+// e.g. to call Resync, SweepOrphans or MalformedResources.
+type Registration[T any, PT interface {
+	*T
+	client.Object
+}] struct {
+	reconciler *Reconciler[T, PT]
+}
+
+// Reconciler returns the reconciler of T, or nil until Controllers.Setup succeeded.
+func (r *Registration[T, PT]) Reconciler() *Reconciler[T, PT] {
+	return r.reconciler
+}
+
+// Register adds the reconciler of T to c.
+// The options take precedence over the shared defaults of c.
+func Register[T any, PT interface {
+	*T
+	client.Object
+}](c *Controllers, opts ...Option[PT]) *Registration[T, PT] {
+	registration := &Registration[T, PT]{}
+	c.registrations = append(c.registrations, controllerRegistration{
+		obj: PT(new(T)),
+		setup: func(ctx context.Context, mgr ctrl.Manager, shared []SharedOption) (err error) {
+			all := make([]Option[PT], 0, len(shared)+len(opts))
+			for _, opt := range shared {
+				all = append(all, opt)
+			}
+			registration.reconciler, err = SetupReconcilerWithOptions[T, PT](ctx, mgr, append(all, opts...)...)
+			return err
+		},
+	})
+	return registration
+}
+
+// Setup registers every reconciler with the manager.
+// It fails without setting up any reconciler if a GVK is registered twice.
+func (c *Controllers) Setup(ctx context.Context, mgr ctrl.Manager) error {
+	gvks := make([]schema.GroupVersionKind, len(c.registrations))
+	seen := make(map[schema.GroupVersionKind]struct{}, len(c.registrations))
+	for i, reg := range c.registrations {
+		gvk, err := apiutil.GVKForObject(reg.obj, mgr.GetScheme())
+		if err != nil {
+			return fmt.Errorf("failed to get GVK for object: %w", err)
+		}
+		if _, ok := seen[gvk]; ok {
+			return fmt.Errorf("%s is registered more than once", gvk)
+		}
+		seen[gvk] = struct{}{}
+		gvks[i] = gvk
+	}
+
+	for i, reg := range c.registrations {
+		if err := reg.setup(ctx, mgr, c.sharedOptions(gvks[i])); err != nil {
+			return fmt.Errorf("failed to set up %s: %w", gvks[i].Kind, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Controllers) sharedOptions(gvk schema.GroupVersionKind) []SharedOption {
+	var opts []SharedOption
+	if c.FinalizerPrefix != "" {
+		opts = append(opts, WithFinalizer(c.FinalizerPrefix+"/"+strings.ToLower(gvk.Kind)))
+	}
+	if c.EventRecorderName != "" {
+		opts = append(opts, WithEventRecorderName(c.EventRecorderName))
+	}
+	if c.RequeuePolicy != nil {
+		opts = append(opts, WithRequeuePolicy(c.RequeuePolicy))
+	}
+	return append(opts, c.Options...)
+}
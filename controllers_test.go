@@ -0,0 +1,103 @@
+package kopper
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlMetrics "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+func newTestManager(t *testing.T, addToScheme ...func(*runtime.Scheme) error) ctrl.Manager {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	for _, add := range append([]func(*runtime.Scheme) error{corev1.AddToScheme}, addToScheme...) {
+		if err := add(s); err != nil {
+			t.Fatalf("failed to build scheme: %v", err)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(&rest.Config{Host: "http://127.0.0.1:1"}, ctrl.Options{
+		Scheme:  s,
+		Metrics: ctrlMetrics.Options{BindAddress: "0"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	return mgr
+}
+
+func TestControllersDuplicateGVK(t *testing.T) {
+	onUpsert := func(context.Context, *corev1.ConfigMap) error { return nil }
+
+	c := &Controllers{FinalizerPrefix: "test.kopper.io"}
+	Register[corev1.ConfigMap](c, WithOnUpsert(onUpsert))
+	Register[corev1.ConfigMap](c, WithOnUpsert(onUpsert))
+
+	err := c.Setup(context.New(), newTestManager(t))
+	if err == nil || !strings.Contains(err.Error(), "registered more than once") {
+		t.Fatalf("expected duplicate GVK error, got %v", err)
+	}
+}
+
+func TestControllersSetup(t *testing.T) {
+	c := &Controllers{
+		FinalizerPrefix: "test.kopper.io",
+		Options:         []SharedOption{WithOnDelete(func(context.Context, string) error { return nil })},
+	}
+	configMaps := Register[corev1.ConfigMap](c, WithOnUpsert(func(context.Context, *corev1.ConfigMap) error { return nil }))
+	secrets := Register[corev1.Secret](c,
+		WithOnUpsert(func(context.Context, *corev1.Secret) error { return nil }),
+		WithFinalizer("secrets.test.kopper.io"),
+	)
+	if configMaps.Reconciler() != nil {
+		t.Error("expected no reconciler before Setup")
+	}
+
+	if err := c.Setup(context.New(), newTestManager(t)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r := configMaps.Reconciler(); r == nil || r.Finalizer != "test.kopper.io/configmap" {
+		t.Errorf("expected the ConfigMap reconciler, got %+v", r)
+	}
+	if r := secrets.Reconciler(); r == nil || r.Finalizer != "secrets.test.kopper.io" {
+		t.Errorf("expected the Secret reconciler, got %+v", r)
+	}
+}
+
+func TestControllersSharedOptions(t *testing.T) {
+	policy := FixedRequeuePolicy{Interval: time.Second}
+	c := &Controllers{
+		FinalizerPrefix:   "test.kopper.io",
+		EventRecorderName: "test-operator",
+		RequeuePolicy:     policy,
+	}
+
+	o := &options{}
+	for _, opt := range append(c.sharedOptions(testGVK), WithFinalizer("override.kopper.io")) {
+		opt(o)
+	}
+	if o.finalizer != "override.kopper.io" {
+		t.Errorf("expected registered finalizer to take precedence, got %q", o.finalizer)
+	}
+
+	o = &options{}
+	for _, opt := range c.sharedOptions(testGVK) {
+		opt(o)
+	}
+	if o.finalizer != "test.kopper.io/testresource" {
+		t.Errorf("expected finalizer derived from the prefix, got %q", o.finalizer)
+	}
+	if o.eventRecorderName != "test-operator" {
+		t.Errorf("expected shared event recorder name, got %q", o.eventRecorderName)
+	}
+	if o.requeuePolicy != policy {
+		t.Errorf("expected shared requeue policy, got %v", o.requeuePolicy)
+	}
+}
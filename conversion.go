@@ -0,0 +1,25 @@
+package kopper
+
+import (
+	"fmt"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// addConversionWebhook serves the conversion webhook of the reconciled kind on /convert.
+// T is the hub version: it is the version that is watched and reconciled,
+// so objects stored in a spoke version are converted by the API server before they reach Reconcile.
+func (r *Reconciler[T, PT]) addConversionWebhook(mgr ctrl.Manager) error {
+	if len(r.SpokeConverters) == 0 {
+		return nil
+	}
+
+	err := ctrl.NewWebhookManagedBy(mgr, PT(new(T))).
+		WithConverter(conversion.NewHubSpokeConverter(PT(new(T)), r.SpokeConverters...)).
+		Complete()
+	if err != nil {
+		return fmt.Errorf("failed to set up conversion webhook for %s: %w", r.gvk.Kind, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package kopper
+
+import (
+	gocontext "context"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// testResourceV1Alpha1 is a spoke version of testResource with a list of messages.
+type testResourceV1Alpha1 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec struct {
+		Messages []string `json:"messages,omitempty"`
+	} `json:"spec,omitempty"`
+}
+
+func (in *testResourceV1Alpha1) DeepCopyObject() runtime.Object {
+	out := &testResourceV1Alpha1{TypeMeta: in.TypeMeta}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.Messages = append([]string(nil), in.Spec.Messages...)
+	return out
+}
+
+func addTestResourceVersions(s *runtime.Scheme) error {
+	s.AddKnownTypeWithName(testGVK, &testResource{})
+	s.AddKnownTypeWithName(testGVK.GroupVersion().WithKind("TestResourceList"), &testResourceList{})
+	metav1.AddToGroupVersion(s, testGVK.GroupVersion())
+
+	spokeGVK := testGVK.GroupKind().WithVersion("v1alpha1")
+	s.AddKnownTypeWithName(spokeGVK, &testResourceV1Alpha1{})
+	metav1.AddToGroupVersion(s, spokeGVK.GroupVersion())
+	return nil
+}
+
+func TestConversionWebhook(t *testing.T) {
+	mgr := newTestManager(t, addTestResourceVersions)
+
+	spokeToHub := func(_ gocontext.Context, src *testResourceV1Alpha1, dst *testResource) error {
+		dst.ObjectMeta = src.ObjectMeta
+		dst.Spec.Message = strings.Join(src.Spec.Messages, ",")
+		return nil
+	}
+	hubToSpoke := func(_ gocontext.Context, src *testResource, dst *testResourceV1Alpha1) error {
+		dst.ObjectMeta = src.ObjectMeta
+		dst.Spec.Messages = strings.Split(src.Spec.Message, ",")
+		return nil
+	}
+
+	_, err := SetupReconcilerWithOptions[testResource](context.New(), mgr,
+		WithFinalizer("test.kopper.io"),
+		WithOnUpsert(func(context.Context, *testResource) error { return nil }),
+		WithOnDelete(func(context.Context, string) error { return nil }),
+		WithConversion(conversion.NewSpokeConverter(&testResourceV1Alpha1{}, hubToSpoke, spokeToHub)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	converter, ok := mgr.GetConverterRegistry().GetConverter(testGVK.GroupKind())
+	if !ok {
+		t.Fatal("expected a converter to be registered for the reconciled kind")
+	}
+
+	src := &testResourceV1Alpha1{}
+	src.SetGroupVersionKind(testGVK.GroupKind().WithVersion("v1alpha1"))
+	src.Spec.Messages = []string{"hello", "world"}
+
+	dst := &testResource{}
+	dst.SetGroupVersionKind(testGVK)
+	if err := converter.ConvertObject(t.Context(), src, dst); err != nil {
+		t.Fatalf("failed to convert to the hub: %v", err)
+	}
+	if dst.Spec.Message != "hello,world" {
+		t.Errorf("expected converted message, got %q", dst.Spec.Message)
+	}
+}
+
+func TestConversionWebhookMissingSpoke(t *testing.T) {
+	mgr := newTestManager(t, addTestResourceVersions)
+
+	noop := func(gocontext.Context, *testResource, *testResource) error { return nil }
+	_, err := SetupReconcilerWithOptions[testResource](context.New(), mgr,
+		WithFinalizer("test.kopper.io"),
+		WithOnUpsert(func(context.Context, *testResource) error { return nil }),
+		WithOnDelete(func(context.Context, string) error { return nil }),
+		WithConversion(conversion.NewSpokeConverter(&testResource{}, noop, noop)),
+	)
+	if err == nil || !strings.Contains(err.Error(), "conversion webhook") {
+		t.Fatalf("expected conversion webhook error, got %v", err)
+	}
+}
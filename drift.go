@@ -0,0 +1,166 @@
+package kopper
+
+import (
+	gocontext "context"
+	"fmt"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	DriftedConditionType = "Drifted"
+
+	ReasonDrifted = "Drifted"
+	ReasonInSync  = "InSync"
+)
+
+const defaultDriftCheckInterval = 10 * time.Minute
+
+// DriftCheckFunc reports whether the persisted state of a resource
+// no longer matches its spec, e.g. because the database row was edited directly.
+type DriftCheckFunc[PT client.Object] func(context.Context, PT) (bool, error)
+
+// DriftMode decides how drifted resources are handled.
+type DriftMode string
+
+const (
+	// DriftModeRepair reconciles drifted resources again, re-running OnUpsertFunc.
+	DriftModeRepair DriftMode = "Repair"
+
+	// DriftModeReport sets the Drifted condition and emits a Warning event.
+	DriftModeReport DriftMode = "Report"
+)
+
+// checkDrift runs DriftCheckFunc against every resource of the reconciled kind.
+func (r *Reconciler[T, PT]) checkDrift(ctx gocontext.Context) error {
+	list, err := r.listResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range list.Items {
+		raw := &list.Items[i]
+		if !raw.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		obj := PT(new(T))
+		if err := fromUnstructured(raw.Object, obj); err != nil {
+			// malformed resources are reported by Reconcile
+			continue
+		}
+		if !r.inScope(obj) {
+			continue
+		}
+
+		resourceName := fmt.Sprintf("%s[%s/%s:%s]", r.gvk.Kind, obj.GetNamespace(), obj.GetName(), obj.GetUID())
+		log := r.objectLogger(ctx, resourceName, obj)
+
+		driftCtx, span := r.startSpan(ctx, spanDriftCheck, obj)
+		started := time.Now()
+		var drifted bool
+		err := r.runCallback(driftCtx, obj, func(ctx context.Context) (err error) {
+			// compare against the spec OnUpsertFunc persisted, i.e. with the defaults applied
+			checked := obj.DeepCopyObject().(PT)
+			if err := r.applyDefaults(ctx, checked); err != nil {
+				return err
+			}
+			drifted, err = r.DriftCheckFunc(ctx, checked)
+			return err
+		})
+		recordReconcile(r.gvk, metricsActionDrift, err)
+		endSpan(span, err)
+		if err != nil {
+			log.WithAction(metricsActionDrift, started).WithError(err).Error("failed to check drift")
+			continue
+		}
+
+		if err := r.handleDrift(ctx, log, obj, drifted); err != nil {
+			log.WithError(err).Error("failed to handle drift")
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler[T, PT]) handleDrift(ctx gocontext.Context, log objectLogger, obj PT, drifted bool) error {
+	if drifted {
+		log.Info(2, "detected drift")
+	}
+
+	if r.DriftMode == DriftModeRepair {
+		if !drifted {
+			return nil
+		}
+
+		r.forcedResyncs.add(client.ObjectKeyFromObject(obj))
+		select {
+		case r.resyncEvents <- event.GenericEvent{Object: obj}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	original := obj.DeepCopyObject()
+	if drifted {
+		// types without conditions get the event on every check
+		if r.isConditionTrue(obj, DriftedConditionType) {
+			return nil
+		}
+		r.Events.Eventf(obj, nil, "Warning", ReasonDrifted, ReasonDrifted, "Persisted state of %s does not match the spec", log.resourceName)
+		if !r.setConditionType(obj, DriftedConditionType, metav1.ConditionTrue, ReasonDrifted, "Persisted state does not match the spec") {
+			return nil
+		}
+	} else if !r.clearDrifted(obj) {
+		return nil
+	}
+
+	return r.updateStatus(ctx, log, obj, original)
+}
+
+// clearDrifted sets a previously reported Drifted condition back to False.
+func (r *Reconciler[T, PT]) clearDrifted(obj PT) bool {
+	if !r.isConditionTrue(obj, DriftedConditionType) {
+		return false
+	}
+
+	return r.setConditionType(obj, DriftedConditionType, metav1.ConditionFalse, ReasonInSync, "")
+}
+
+// addDriftCheck registers the periodic drift check with the manager.
+// It only runs on the leader.
+func (r *Reconciler[T, PT]) addDriftCheck(mgr ctrl.Manager) error {
+	if r.DriftCheckFunc == nil {
+		return nil
+	}
+
+	switch r.DriftMode {
+	case "":
+		r.DriftMode = DriftModeReport
+	case DriftModeRepair, DriftModeReport:
+	default:
+		return fmt.Errorf("unknown drift mode %q", r.DriftMode)
+	}
+
+	interval := r.DriftCheckInterval
+	if interval <= 0 {
+		interval = defaultDriftCheckInterval
+	}
+
+	return mgr.Add(manager.RunnableFunc(func(ctx gocontext.Context) error {
+		wait.UntilWithContext(ctx, func(ctx gocontext.Context) {
+			if err := r.checkDrift(ctx); err != nil {
+				r.kindLogger(ctx).WithError(err).Error("failed to check drift")
+			}
+		}, interval)
+		return nil
+	}))
+}
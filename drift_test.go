@@ -0,0 +1,129 @@
+package kopper
+
+import (
+	gocontext "context"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	corev1 "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestCheckDriftReport(t *testing.T) {
+	r, recorder := newTestReconciler(t, newTestResource("drifted"), newTestResource("in-sync"))
+	r.DriftMode = DriftModeReport
+
+	drifted := true
+	r.DriftCheckFunc = func(_ context.Context, obj *testResource) (bool, error) {
+		return obj.Name == "drifted" && drifted, nil
+	}
+
+	if err := r.checkDrift(gocontext.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	driftedCondition := func(name string) *metav1.Condition {
+		obj := &testResource{}
+		if err := r.Get(gocontext.Background(), types.NamespacedName{Namespace: "default", Name: name}, obj); err != nil {
+			t.Fatalf("failed to get %s: %v", name, err)
+		}
+		return k8smeta.FindStatusCondition(obj.Status.Conditions, DriftedConditionType)
+	}
+
+	if c := driftedCondition("drifted"); c == nil || c.Status != metav1.ConditionTrue || c.Reason != ReasonDrifted {
+		t.Errorf("expected Drifted=True, got %+v", c)
+	}
+	if c := driftedCondition("in-sync"); c != nil {
+		t.Errorf("expected no Drifted condition on an in-sync resource, got %+v", c)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected 1 warning event, got %d", len(recorder.Events))
+	}
+
+	drifted = false
+	if err := r.checkDrift(gocontext.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := driftedCondition("drifted"); c == nil || c.Status != metav1.ConditionFalse || c.Reason != ReasonInSync {
+		t.Errorf("expected Drifted=False once in sync again, got %+v", c)
+	}
+}
+
+func TestCheckDriftRepair(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("drifted"), newTestResource("in-sync"))
+	r.DriftMode = DriftModeRepair
+	r.resyncEvents = make(chan event.GenericEvent, 10)
+	r.DriftCheckFunc = func(_ context.Context, obj *testResource) (bool, error) {
+		return obj.Name == "drifted", nil
+	}
+
+	if err := r.checkDrift(gocontext.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	close(r.resyncEvents)
+	var enqueued []string
+	for e := range r.resyncEvents {
+		enqueued = append(enqueued, e.Object.GetName())
+	}
+	if len(enqueued) != 1 || enqueued[0] != "drifted" {
+		t.Errorf("expected only the drifted resource to be enqueued, got %v", enqueued)
+	}
+}
+
+func TestCheckDriftReportWithoutConditions(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "drifted", Namespace: "default"}}
+
+	recorder := events.NewFakeRecorder(10)
+	r := &Reconciler[corev1.ConfigMap, *corev1.ConfigMap]{
+		Client:         fake.NewClientBuilder().WithScheme(s).WithObjects(configMap).Build(),
+		DutyContext:    context.New(),
+		Events:         recorder,
+		DriftMode:      DriftModeReport,
+		DriftCheckFunc: func(context.Context, *corev1.ConfigMap) (bool, error) { return true, nil },
+		gvk:            corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+		forcedResyncs:  newKeySet(),
+	}
+
+	if err := r.checkDrift(gocontext.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a warning event for a type without conditions, got %d", len(recorder.Events))
+	}
+}
+
+func TestCheckDriftAppliesDefaults(t *testing.T) {
+	obj := newTestResource("defaulted")
+	obj.Spec.Message = ""
+	r, recorder := newTestReconciler(t, obj)
+	r.DriftMode = DriftModeReport
+	r.DefaultFunc = func(_ context.Context, obj *testResource) error {
+		if obj.Spec.Message == "" {
+			obj.Spec.Message = "default"
+		}
+		return nil
+	}
+	r.DriftCheckFunc = func(_ context.Context, obj *testResource) (bool, error) {
+		// the persisted spec has the defaults applied
+		return obj.Spec.Message != "default", nil
+	}
+
+	if err := r.checkDrift(gocontext.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no drift once the defaults are applied, got %d events", len(recorder.Events))
+	}
+}
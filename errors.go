@@ -0,0 +1,84 @@
+package kopper
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PermanentError marks a callback error that will not succeed on retry,
+// e.g. a spec rejected by validation in the persistence layer.
+// The resource is not requeued until its generation changes.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfterError asks for the resource to be reconciled again after the given duration,
+// overriding the RequeuePolicy. Non-positive durations are handled by the RequeuePolicy.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// IgnoredError marks a callback error that is logged but otherwise treated as success.
+type IgnoredError struct {
+	Err error
+}
+
+func (e *IgnoredError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *IgnoredError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err as a PermanentError. It returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// RetryAfter wraps err as a RetryAfterError. It returns nil if err is nil.
+func RetryAfter(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryAfterError{Err: err, After: after}
+}
+
+// Ignore wraps err as an IgnoredError. It returns nil if err is nil.
+func Ignore(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &IgnoredError{Err: err}
+}
+
+func isPermanentError(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
+
+func isIgnoredError(err error) bool {
+	var ignoredErr *IgnoredError
+	return errors.As(err, &ignoredErr)
+}
@@ -0,0 +1,169 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	defaultDatabasePingTimeout = 5 * time.Second
+	defaultUpsertErrorWindow   = 5 * time.Minute
+	defaultMinUpsertCalls      = 10
+
+	// callStatsBucket is the granularity of callStats, which covers the last hour.
+	callStatsBucket  = 10 * time.Second
+	callStatsBuckets = int(time.Hour / callStatsBucket)
+)
+
+// HealthCheckOptions configures the readiness checks registered by AddHealthChecks.
+type HealthCheckOptions struct {
+	// DatabasePing fails readiness when the database of the duty context can't be pinged
+	// within DatabasePingTimeout, which defaults to 5s.
+	DatabasePing        bool
+	DatabasePingTimeout time.Duration
+
+	// MaxUpsertErrorRatio fails readiness when more than this fraction of the OnUpsertFunc calls
+	// of the last UpsertErrorWindow failed with a retryable error. Zero disables the check.
+	// The ratio is only checked once there were MinUpsertCalls calls in the window.
+	// UpsertErrorWindow defaults to 5m, up to 1h, and MinUpsertCalls to 10.
+	MaxUpsertErrorRatio float64
+	UpsertErrorWindow   time.Duration
+	MinUpsertCalls      int
+}
+
+// AddHealthChecks registers readiness checks with the manager:
+// the informer caches must have synced, and optionally the database must be reachable
+// and the OnUpsertFunc error ratio must be below a threshold.
+// The checks are served on ManagerOptions.HealthProbeBindAddress.
+func AddHealthChecks(ctx context.Context, mgr ctrl.Manager, opts HealthCheckOptions) error {
+	if err := mgr.AddReadyzCheck("cache-sync", cacheSyncCheck(mgr)); err != nil {
+		return fmt.Errorf("error adding cache sync check: %w", err)
+	}
+
+	if opts.DatabasePing {
+		timeout := opts.DatabasePingTimeout
+		if timeout <= 0 {
+			timeout = defaultDatabasePingTimeout
+		}
+		if err := mgr.AddReadyzCheck("database", databaseCheck(ctx, timeout)); err != nil {
+			return fmt.Errorf("error adding database check: %w", err)
+		}
+	}
+
+	if opts.MaxUpsertErrorRatio > 0 {
+		window := opts.UpsertErrorWindow
+		if window <= 0 {
+			window = defaultUpsertErrorWindow
+		}
+		minCalls := opts.MinUpsertCalls
+		if minCalls <= 0 {
+			minCalls = defaultMinUpsertCalls
+		}
+		if err := mgr.AddReadyzCheck("upsert-errors", upsertErrorCheck(upsertStats, opts.MaxUpsertErrorRatio, window, minCalls)); err != nil {
+			return fmt.Errorf("error adding upsert error check: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func cacheSyncCheck(mgr ctrl.Manager) func(*http.Request) error {
+	return func(req *http.Request) error {
+		ctx, cancel := gocontext.WithTimeout(req.Context(), time.Second)
+		defer cancel()
+
+		if !mgr.GetCache().WaitForCacheSync(ctx) {
+			return errors.New("informer caches have not synced")
+		}
+		return nil
+	}
+}
+
+func databaseCheck(ctx context.Context, timeout time.Duration) func(*http.Request) error {
+	return func(req *http.Request) error {
+		pool := ctx.Pool()
+		if pool == nil {
+			return errors.New("database is not configured")
+		}
+
+		pingCtx, cancel := gocontext.WithTimeout(req.Context(), timeout)
+		defer cancel()
+
+		if err := pool.Ping(pingCtx); err != nil {
+			return fmt.Errorf("failed to ping database: %w", err)
+		}
+		return nil
+	}
+}
+
+func upsertErrorCheck(stats *callStats, maxRatio float64, window time.Duration, minCalls int) func(*http.Request) error {
+	return func(*http.Request) error {
+		calls, failures := stats.count(time.Now(), window)
+		if calls < minCalls {
+			return nil
+		}
+
+		if ratio := float64(failures) / float64(calls); ratio > maxRatio {
+			return fmt.Errorf("%d of %d upserts failed in the last %s", failures, calls, window)
+		}
+		return nil
+	}
+}
+
+// upsertStats counts the OnUpsertFunc calls of every reconciler.
+var upsertStats = &callStats{}
+
+// callStats counts calls and failures in 10s buckets over the last hour.
+type callStats struct {
+	mu      sync.Mutex
+	buckets [callStatsBuckets]callBucket
+}
+
+type callBucket struct {
+	index    int64
+	calls    int
+	failures int
+}
+
+func callStatsIndex(t time.Time) int64 {
+	return t.UnixNano() / int64(callStatsBucket)
+}
+
+func (s *callStats) record(now time.Time, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := callStatsIndex(now)
+	bucket := &s.buckets[index%int64(callStatsBuckets)]
+	if bucket.index != index {
+		*bucket = callBucket{index: index}
+	}
+
+	bucket.calls++
+	if failed {
+		bucket.failures++
+	}
+}
+
+// count returns the calls and failures recorded within window of now.
+func (s *callStats) count(now time.Time, window time.Duration) (calls, failures int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newest := callStatsIndex(now)
+	oldest := callStatsIndex(now.Add(-window))
+	for _, bucket := range s.buckets {
+		if bucket.index > oldest && bucket.index <= newest {
+			calls += bucket.calls
+			failures += bucket.failures
+		}
+	}
+	return calls, failures
+}
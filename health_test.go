@@ -0,0 +1,72 @@
+package kopper
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/context"
+)
+
+func TestCallStats(t *testing.T) {
+	stats := &callStats{}
+	now := time.Now()
+
+	stats.record(now.Add(-10*time.Minute), true)
+	stats.record(now.Add(-time.Minute), true)
+	stats.record(now, false)
+	stats.record(now, false)
+
+	if calls, failures := stats.count(now, 5*time.Minute); calls != 3 || failures != 1 {
+		t.Errorf("expected 3 calls and 1 failure in the last 5m, got %d and %d", calls, failures)
+	}
+	if calls, failures := stats.count(now, time.Hour); calls != 4 || failures != 2 {
+		t.Errorf("expected 4 calls and 2 failures in the last hour, got %d and %d", calls, failures)
+	}
+	if calls, _ := stats.count(now.Add(2*time.Hour), time.Hour); calls != 0 {
+		t.Errorf("expected old buckets to be ignored, got %d calls", calls)
+	}
+}
+
+func TestUpsertErrorCheck(t *testing.T) {
+	stats := &callStats{}
+	check := upsertErrorCheck(stats, 0.5, time.Minute, 4)
+	req := httptest.NewRequest("GET", "/readyz", nil)
+
+	for range 3 {
+		stats.record(time.Now(), true)
+	}
+	if err := check(req); err != nil {
+		t.Errorf("expected the ratio to be ignored below the minimum calls, got %v", err)
+	}
+
+	stats.record(time.Now(), true)
+	if err := check(req); err == nil || !strings.Contains(err.Error(), "4 of 4 upserts failed") {
+		t.Errorf("expected the check to fail, got %v", err)
+	}
+
+	for range 4 {
+		stats.record(time.Now(), false)
+	}
+	if err := check(req); err != nil {
+		t.Errorf("expected the check to pass at a 50%% error ratio, got %v", err)
+	}
+}
+
+func TestDatabaseCheckWithoutPool(t *testing.T) {
+	check := databaseCheck(context.New(), time.Second)
+	if err := check(httptest.NewRequest("GET", "/readyz", nil)); err == nil {
+		t.Error("expected the check to fail without a database")
+	}
+}
+
+func TestAddHealthChecks(t *testing.T) {
+	err := AddHealthChecks(context.New(), newTestManager(t), HealthCheckOptions{
+		DatabasePing:        true,
+		MaxUpsertErrorRatio: 0.5,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
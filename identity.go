@@ -0,0 +1,82 @@
+package kopper
+
+import (
+	gocontext "context"
+	"maps"
+
+	"github.com/flanksource/duty/context"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// ObjectIdentity identifies the resource a callback was called for.
+type ObjectIdentity struct {
+	GVK         schema.GroupVersionKind
+	Namespace   string
+	Name        string
+	UID         types.UID
+	Generation  int64
+	ReconcileID types.UID
+}
+
+type objectIdentityKey struct{}
+
+// ObjectIdentityFromContext returns the identity of the resource a callback was called for.
+func ObjectIdentityFromContext(ctx gocontext.Context) (ObjectIdentity, bool) {
+	id, ok := ctx.Value(objectIdentityKey{}).(ObjectIdentity)
+	return id, ok
+}
+
+// keysAndValues returns the identity as logger fields, leaving out the unknown ones.
+func (id ObjectIdentity) keysAndValues() []any {
+	kv := []any{"gvk", id.GVK.String()}
+	if id.Namespace != "" {
+		kv = append(kv, "namespace", id.Namespace)
+	}
+	if id.Name != "" {
+		kv = append(kv, "name", id.Name)
+	}
+	if id.UID != "" {
+		kv = append(kv, "uid", string(id.UID))
+	}
+	if id.Generation != 0 {
+		kv = append(kv, "generation", id.Generation)
+	}
+	if id.ReconcileID != "" {
+		kv = append(kv, "reconcileID", string(id.ReconcileID))
+	}
+	return kv
+}
+
+// objectIdentity returns the identity of obj, which may be nil, reconciled with ctx.
+func (r *Reconciler[T, PT]) objectIdentity(ctx gocontext.Context, obj client.Object) ObjectIdentity {
+	id := ObjectIdentity{GVK: r.gvk, ReconcileID: controller.ReconcileIDFromContext(ctx)}
+	if obj != nil {
+		id.Namespace = obj.GetNamespace()
+		id.Name = obj.GetName()
+		id.UID = obj.GetUID()
+		id.Generation = obj.GetGeneration()
+	}
+	return id
+}
+
+// withObjectIdentity returns a copy of ctx carrying id as a value, as logger fields
+// and as duty logging values, which end up in its errors and spans.
+func withObjectIdentity(ctx context.Context, id ObjectIdentity) context.Context {
+	kv := id.keysAndValues()
+
+	// duty's WithLoggingValues modifies the values map in place, which is shared with the parent context
+	values := map[string]any{}
+	if parent, ok := ctx.Value("values").(map[string]any); ok {
+		maps.Copy(values, parent)
+	}
+	for i := 0; i < len(kv); i += 2 {
+		values[kv[i].(string)] = kv[i+1]
+	}
+
+	ctx = ctx.WithValue(objectIdentityKey{}, id).WithValue("values", values)
+	ctx.Logger = ctx.GetLogger().WithValues(kv...)
+	return ctx
+}
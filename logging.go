@@ -2,10 +2,14 @@ package kopper
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // All Error logs are emitted
@@ -90,3 +94,88 @@ func NewControllerRuntimeLogger() logr.Logger {
 	}
 	return logr.FromSlogHandler(handler)
 }
+
+// LogFormat selects how kopper logs the resources it reconciles.
+type LogFormat string
+
+const (
+	// LogFormatStructured logs the identity of the resource, the action, its duration and error
+	// as key/value fields, e.g. msg="failed to upsert" namespace=default name=foo error="...".
+	LogFormatStructured LogFormat = "Structured"
+
+	// LogFormatText logs them as a single line, e.g. "[kopper] failed to upsert Kind[default/foo:uid]: ...".
+	LogFormatText LogFormat = "Text"
+)
+
+// objectLogger logs about a resource, or the whole reconciled kind, in the reconciler's LogFormat.
+type objectLogger struct {
+	format       LogFormat
+	resourceName string
+	fields       []any
+	err          error
+}
+
+// objectLogger returns the logger for obj, which may be nil, named resourceName in the text format.
+func (r *Reconciler[T, PT]) objectLogger(ctx context.Context, resourceName string, obj client.Object) objectLogger {
+	return objectLogger{
+		format:       r.LogFormat,
+		resourceName: resourceName,
+		fields:       r.objectIdentity(ctx, obj).keysAndValues(),
+	}
+}
+
+// kindLogger returns the logger for messages about every resource of the reconciled kind.
+func (r *Reconciler[T, PT]) kindLogger(ctx context.Context) objectLogger {
+	return r.objectLogger(ctx, r.gvk.Kind, nil)
+}
+
+// With returns a logger that adds the key/value pairs to the structured format.
+func (l objectLogger) With(keysAndValues ...any) objectLogger {
+	l.fields = append(slices.Clip(l.fields), keysAndValues...)
+	return l
+}
+
+// WithAction returns a logger that records the action and how long it took since started.
+func (l objectLogger) WithAction(action string, started time.Time) objectLogger {
+	return l.With("action", action, "duration", time.Since(started))
+}
+
+// WithError returns a logger that appends err to the message.
+func (l objectLogger) WithError(err error) objectLogger {
+	l.err = err
+	return l
+}
+
+// Info logs msg at the given verbosity.
+func (l objectLogger) Info(level int, msg string) {
+	if v := klog.V(level); v.Enabled() {
+		if l.format == LogFormatText {
+			v.Infof("%s", l.text(msg))
+		} else {
+			v.WithValues(l.keysAndValues()...).Infof("%s", msg)
+		}
+	}
+}
+
+// Error logs msg as an error.
+func (l objectLogger) Error(msg string) {
+	if l.format == LogFormatText {
+		klog.Errorf("%s", l.text(msg))
+	} else {
+		klog.WithValues(l.keysAndValues()...).Errorf("%s", msg)
+	}
+}
+
+func (l objectLogger) text(msg string) string {
+	if l.err != nil {
+		return fmt.Sprintf("[kopper] %s %s: %v", msg, l.resourceName, l.err)
+	}
+	return fmt.Sprintf("[kopper] %s %s", msg, l.resourceName)
+}
+
+func (l objectLogger) keysAndValues() []any {
+	if l.err != nil {
+		return append(slices.Clip(l.fields), "error", l.err.Error())
+	}
+	return l.fields
+}
@@ -3,9 +3,12 @@ package kopper
 import (
 	"bytes"
 	"context"
+	"errors"
 	"log/slog"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/go-logr/logr"
@@ -281,3 +284,57 @@ func TestDefaultWarnLevelSuppression(t *testing.T) {
 		t.Errorf("expected error message in output, got: %q", buf.String())
 	}
 }
+
+// ansiEscape matches the colors of the console log output.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func TestObjectLoggerFormats(t *testing.T) {
+	var buf bytes.Buffer
+	output := logger.GetOutput()
+	logger.SetOutput(&buf)
+	t.Cleanup(func() { logger.SetOutput(output) })
+
+	obj := newTestResource("logged")
+	r, _ := newTestReconciler(t, obj)
+	resourceName := "TestResource[default/logged:logged-uid]"
+
+	r.LogFormat = LogFormatText
+	r.objectLogger(context.Background(), resourceName, obj).WithError(errors.New("boom")).Error("failed to upsert")
+	if text := buf.String(); !strings.Contains(text, "[kopper] failed to upsert "+resourceName+": boom") {
+		t.Errorf("expected the text format, got %q", text)
+	}
+
+	buf.Reset()
+	r.LogFormat = LogFormatStructured
+	r.objectLogger(context.Background(), resourceName, obj).
+		WithAction(metricsActionUpsert, time.Now()).
+		WithError(errors.New("boom")).
+		Error("failed to upsert")
+	structured := ansiEscape.ReplaceAllString(buf.String(), "")
+	for _, field := range []string{"namespace=default", "name=logged", "uid=logged-uid", "generation=1", "action=upsert", "duration=", "error=boom"} {
+		if !strings.Contains(structured, field) {
+			t.Errorf("expected %s in the structured format, got %q", field, structured)
+		}
+	}
+	if strings.Contains(structured, resourceName) {
+		t.Errorf("expected the structured format not to embed the resource name, got %q", structured)
+	}
+}
+
+func TestKindLogger(t *testing.T) {
+	var buf bytes.Buffer
+	output := logger.GetOutput()
+	logger.SetOutput(&buf)
+	t.Cleanup(func() { logger.SetOutput(output) })
+
+	r, _ := newTestReconciler(t)
+	r.kindLogger(context.Background()).WithError(errors.New("boom")).Error("failed to resync")
+
+	structured := ansiEscape.ReplaceAllString(buf.String(), "")
+	if !strings.Contains(structured, `gvk="test.kopper.io/v1, Kind=TestResource"`) || !strings.Contains(structured, "error=boom") {
+		t.Errorf("expected the gvk and error fields, got %q", structured)
+	}
+	if strings.Contains(structured, "[kopper]") {
+		t.Errorf("expected the structured format, got %q", structured)
+	}
+}
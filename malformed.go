@@ -0,0 +1,196 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// MalformedResource is a resource that does not convert to the reconciled type.
+type MalformedResource struct {
+	Namespace  string
+	Name       string
+	UID        types.UID
+	Generation int64
+	Error      string
+}
+
+// MalformedResources lists the resources in scope of the reconciled kind that do not convert to PT,
+// even after MigrateUnstructuredFunc.
+func (r *Reconciler[T, PT]) MalformedResources(ctx gocontext.Context) ([]MalformedResource, error) {
+	list, err := r.listResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var malformed []MalformedResource
+	for i := range list.Items {
+		raw := &list.Items[i]
+		if !r.inScope(raw) {
+			continue
+		}
+
+		err := fromUnstructured(raw.Object, PT(new(T)))
+		if err != nil && r.MigrateUnstructuredFunc != nil {
+			resourceName := fmt.Sprintf("%s[%s/%s:%s]", r.gvk.Kind, raw.GetNamespace(), raw.GetName(), raw.GetUID())
+			_, err = r.migrate(r.objectLogger(ctx, resourceName, raw), raw, PT(new(T)), err)
+		}
+		if err == nil {
+			continue
+		}
+
+		malformed = append(malformed, MalformedResource{
+			Namespace:  raw.GetNamespace(),
+			Name:       raw.GetName(),
+			UID:        raw.GetUID(),
+			Generation: raw.GetGeneration(),
+			Error:      err.Error(),
+		})
+	}
+
+	return malformed, nil
+}
+
+// rawConditions returns the status conditions of a resource that can't be converted to PT.
+// Conditions that can't be read either are dropped.
+func rawConditions(raw *unstructured.Unstructured) []metav1.Condition {
+	items, _, _ := unstructured.NestedSlice(raw.Object, "status", "conditions")
+
+	var conditions []metav1.Condition
+	for _, item := range items {
+		u, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		var condition metav1.Condition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, &condition); err == nil {
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions
+}
+
+// isMarkedMalformed reports whether the current generation of raw was already reported as malformed.
+func (r *Reconciler[T, PT]) isMarkedMalformed(raw *unstructured.Unstructured) bool {
+	ready := k8smeta.FindStatusCondition(rawConditions(raw), ReadyConditionType)
+	return ready != nil &&
+		ready.Status == metav1.ConditionFalse &&
+		ready.Reason == ReasonMalformedResource &&
+		ready.ObservedGeneration == raw.GetGeneration()
+}
+
+// markMalformed sets the Ready condition of raw to False with ReasonMalformedResource.
+// The typed object can't be built, so the status is patched as unstructured.
+// It is a no-op for types that do not implement StatusConditioner.
+func (r *Reconciler[T, PT]) markMalformed(ctx gocontext.Context, raw *unstructured.Unstructured, convertErr error) error {
+	if _, ok := any(PT(new(T))).(StatusConditioner); !ok {
+		return nil
+	}
+
+	conditions := rawConditions(raw)
+	k8smeta.SetStatusCondition(&conditions, metav1.Condition{
+		Type:               ReadyConditionType,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonMalformedResource,
+		Message:            malformedResourceMessage(convertErr),
+		ObservedGeneration: raw.GetGeneration(),
+		LastTransitionTime: metav1.Now(),
+	})
+
+	items := make([]any, 0, len(conditions))
+	for i := range conditions {
+		item, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&conditions[i])
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+
+	patch := client.MergeFrom(raw.DeepCopy())
+	if err := unstructured.SetNestedSlice(raw.Object, items, "status", "conditions"); err != nil {
+		return err
+	}
+	return r.Status().Patch(ctx, raw, patch)
+}
+
+// deleteMalformed handles the deletion of a resource that does not convert to PT.
+// OnDeleteObjectFunc is given a PT with only the metadata of the resource.
+// Out of scope resources are handled like releaseOutOfScope does.
+func (r *Reconciler[T, PT]) deleteMalformed(ctx gocontext.Context, req ctrl.Request, log objectLogger, raw *unstructured.Unstructured) (ctrl.Result, error) {
+	inScope := r.inScope(raw)
+	if !controllerutil.ContainsFinalizer(raw, r.Finalizer) || (!inScope && !r.isOwned(raw)) {
+		return ctrl.Result{}, nil
+	}
+
+	if !inScope && r.OutOfScopeAction != OutOfScopeDelete {
+		log.Info(2, "orphaning out of scope resource")
+		return r.removeMalformedFinalizer(ctx, req, log, raw)
+	}
+
+	// metadata converts even when the spec does not
+	obj := PT(new(T))
+	if err := fromUnstructured(map[string]any{
+		"apiVersion": raw.GetAPIVersion(),
+		"kind":       raw.GetKind(),
+		"metadata":   raw.Object["metadata"],
+	}, obj); err != nil {
+		return r.requeue(req, raw, err, 2*time.Minute)
+	}
+
+	log.Info(2, "deleting malformed resource")
+	deleteCtx, deleteSpan := r.startSpan(ctx, spanDelete, raw)
+	started := time.Now()
+	err := r.runCallback(deleteCtx, raw, func(ctx context.Context) error {
+		return r.delete(ctx, obj)
+	})
+	recordCallback(r.gvk, metricsActionDelete, started, err)
+	endSpan(deleteSpan, err)
+
+	var retryErr *RetryAfterError
+	switch {
+	case isIgnoredError(err):
+		log.WithAction(metricsActionDelete, started).WithError(err).Info(2, "ignoring delete error")
+	case isPermanentError(err):
+		log.WithAction(metricsActionDelete, started).WithError(err).Error("failed to delete malformed resource")
+		r.attempts.reset(req.NamespacedName)
+		r.Events.Eventf(raw, nil, "Warning", ReasonPermanentDeleteFailure, ReasonPermanentDeleteFailure, "%v", err)
+		return ctrl.Result{}, nil
+	case errors.As(err, &retryErr) && retryErr.After > 0:
+		log.WithAction(metricsActionDelete, started).WithError(err).Error("failed to delete malformed resource")
+		r.attempts.failed(req.NamespacedName)
+		return ctrl.Result{RequeueAfter: retryErr.After}, nil
+	case err != nil:
+		log.WithAction(metricsActionDelete, started).WithError(err).Error("failed to delete malformed resource")
+		return r.requeue(req, raw, err, 2*time.Minute)
+	}
+
+	r.Events.Eventf(raw, nil, "Normal", "Deleted", "Deleted", "Deleted %s", log.resourceName)
+	return r.removeMalformedFinalizer(ctx, req, log, raw)
+}
+
+// removeMalformedFinalizer removes the finalizer and the ScopeAnnotation of a resource that does not convert to PT.
+func (r *Reconciler[T, PT]) removeMalformedFinalizer(ctx gocontext.Context, req ctrl.Request, log objectLogger, raw *unstructured.Unstructured) (ctrl.Result, error) {
+	controllerutil.RemoveFinalizer(raw, r.Finalizer)
+	annotations := raw.GetAnnotations()
+	delete(annotations, ScopeAnnotation)
+	raw.SetAnnotations(annotations)
+	if err := r.updateFinalizers(ctx, raw); err != nil {
+		log.WithError(err).Error("failed to update finalizers")
+		return r.requeue(req, raw, err, 2*time.Minute)
+	}
+
+	r.attempts.reset(req.NamespacedName)
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,113 @@
+package kopper
+
+import (
+	gocontext "context"
+	"slices"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestReconcileMalformedResource(t *testing.T) {
+	r, recorder := newLegacyTestReconciler(t)
+
+	for range 2 {
+		result, _, err := reconcileTestResource(t, r, "legacy")
+		if err != nil {
+			t.Fatalf("expected malformed resources not to be retried, got %v", err)
+		}
+		if !result.IsZero() {
+			t.Errorf("expected malformed resources not to be requeued, got %+v", result)
+		}
+	}
+
+	_, obj, _ := reconcileTestResource(t, r, "legacy")
+	ready := k8smeta.FindStatusCondition(obj.Status.Conditions, ReadyConditionType)
+	if ready == nil || ready.Status != metav1.ConditionFalse || ready.Reason != ReasonMalformedResource || ready.ObservedGeneration != obj.Generation {
+		t.Errorf("expected Ready=False/%s, got %+v", ReasonMalformedResource, ready)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a single MalformedResource event, got %d", len(recorder.Events))
+	}
+
+	malformed, err := r.MalformedResources(gocontext.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(malformed) != 1 || malformed[0].Name != "legacy" || malformed[0].UID != obj.UID || malformed[0].Error == "" {
+		t.Errorf("expected the legacy resource to be listed, got %+v", malformed)
+	}
+
+	r.MigrateUnstructuredFunc = migrateTestResource
+	if malformed, _ := r.MalformedResources(gocontext.Background()); len(malformed) != 0 {
+		t.Errorf("expected migrated resources not to be listed, got %+v", malformed)
+	}
+}
+
+func TestReconcileDeletesMalformedResource(t *testing.T) {
+	for _, withObject := range []bool{false, true} {
+		r, _ := newLegacyTestReconciler(t)
+
+		var deleted []string
+		if withObject {
+			r.OnDeleteFunc = nil
+			r.OnDeleteObjectFunc = func(_ context.Context, obj *testResource) error {
+				deleted = append(deleted, string(obj.UID))
+				return nil
+			}
+		} else {
+			r.OnDeleteFunc = func(_ context.Context, id string) error {
+				deleted = append(deleted, id)
+				return nil
+			}
+		}
+
+		_, obj, err := reconcileTestResource(t, r, "legacy")
+		if err != nil {
+			t.Fatalf("withObject=%v: unexpected error: %v", withObject, err)
+		}
+		if err := r.Delete(gocontext.Background(), obj); err != nil {
+			t.Fatalf("withObject=%v: failed to delete legacy: %v", withObject, err)
+		}
+
+		key := types.NamespacedName{Namespace: "default", Name: "legacy"}
+		if _, err := r.Reconcile(gocontext.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("withObject=%v: unexpected error: %v", withObject, err)
+		}
+		if !slices.Equal(deleted, []string{"legacy-uid"}) {
+			t.Errorf("withObject=%v: expected the delete callback to be called with the UID, got %v", withObject, deleted)
+		}
+		if err := r.Get(gocontext.Background(), key, &testResource{}); !apiErrors.IsNotFound(err) {
+			t.Errorf("withObject=%v: expected the finalizer to be removed, got %v", withObject, err)
+		}
+	}
+}
+
+func TestReconcileMalformedResourceOutOfScope(t *testing.T) {
+	r, recorder := newLegacyTestReconciler(t)
+	r.Namespaces = []string{"other"}
+
+	_, obj, err := reconcileTestResource(t, r, "legacy")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready := k8smeta.FindStatusCondition(obj.Status.Conditions, ReadyConditionType); ready != nil {
+		t.Errorf("expected no Ready condition on an out of scope resource, got %+v", ready)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no events for an out of scope resource, got %d", len(recorder.Events))
+	}
+
+	malformed, err := r.MalformedResources(gocontext.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(malformed) != 0 {
+		t.Errorf("expected out of scope resources not to be listed, got %+v", malformed)
+	}
+}
@@ -2,18 +2,25 @@ package kopper
 
 import (
 	"fmt"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	ctrlMetrics "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 var (
@@ -27,6 +34,85 @@ func init() {
 type ManagerOptions struct {
 	LeaderElectionID string
 	AddToSchemeFunc  func(*runtime.Scheme) error
+
+	// RestConfig is the config used to talk to the API server.
+	// Defaults to ctrl.GetConfig(), i.e. the --kubeconfig flag, KUBECONFIG or the in-cluster config.
+	RestConfig *rest.Config
+
+	// MetricsBindAddress is the address the metrics endpoint binds to, e.g. ":8080".
+	// Defaults to "0", which disables the endpoint.
+	MetricsBindAddress string
+
+	// HealthProbeBindAddress is the address the /healthz and /readyz endpoints bind to, e.g. ":8081".
+	// Empty disables the endpoints.
+	HealthProbeBindAddress string
+
+	// PprofBindAddress is the address the pprof endpoints bind to, e.g. ":8082".
+	// Empty disables the endpoints.
+	PprofBindAddress string
+
+	// WebhookPort and WebhookCertDir configure the server of the admission and conversion webhooks.
+	// They default to 9443 and <temp-dir>/k8s-webhook-server/serving-certs.
+	WebhookPort    int
+	WebhookCertDir string
+
+	// SyncPeriod is how often the informers resync, which reconciles every watched resource again.
+	// Defaults to 10 hours.
+	SyncPeriod time.Duration
+
+	// LeaderElectionNamespace is the namespace of the leader election lease.
+	// Defaults to the namespace the operator runs in.
+	LeaderElectionNamespace string
+
+	// LeaseDuration, RenewDeadline and RetryPeriod tune leader election.
+	// They default to 15s, 10s and 2s.
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+
+	// GracefulShutdownTimeout is how long running reconciles are given to finish on shutdown.
+	// Defaults to 30s.
+	GracefulShutdownTimeout time.Duration
+
+	// Namespaces and LabelSelector limit the cached objects of the ScopedObjects kinds,
+	// e.g. the reconciled kinds. Other kinds, such as Secrets and ConfigMaps, are cached unfiltered.
+	// Resources moving out of a cache-level scope look deleted to the cache and are
+	// never released, so use WithNamespaces and WithLabelSelector when they can.
+	Namespaces    []string
+	LabelSelector labels.Selector
+	ScopedObjects []client.Object
+}
+
+// durationOrNil maps a zero duration to nil, so controller-runtime applies its default.
+func durationOrNil(d time.Duration) *time.Duration {
+	if d == 0 {
+		return nil
+	}
+	return &d
+}
+
+// cacheOptions scopes the cache of the ScopedObjects kinds to Namespaces and LabelSelector.
+func cacheOptions(opts *ManagerOptions) (cache.Options, error) {
+	cacheOpts := cache.Options{SyncPeriod: durationOrNil(opts.SyncPeriod)}
+	if len(opts.Namespaces) == 0 && opts.LabelSelector == nil {
+		return cacheOpts, nil
+	}
+	if len(opts.ScopedObjects) == 0 {
+		return cacheOpts, fmt.Errorf("ScopedObjects are required by Namespaces and LabelSelector")
+	}
+
+	cacheOpts.ByObject = make(map[client.Object]cache.ByObject, len(opts.ScopedObjects))
+	for _, obj := range opts.ScopedObjects {
+		scope := cache.ByObject{Label: opts.LabelSelector}
+		if len(opts.Namespaces) > 0 {
+			scope.Namespaces = make(map[string]cache.Config, len(opts.Namespaces))
+			for _, namespace := range opts.Namespaces {
+				scope.Namespaces[namespace] = cache.Config{}
+			}
+		}
+		cacheOpts.ByObject[obj] = scope
+	}
+	return cacheOpts, nil
 }
 
 func Manager(opts *ManagerOptions) (manager.Manager, error) {
@@ -34,18 +120,52 @@ func Manager(opts *ManagerOptions) (manager.Manager, error) {
 		opts = &ManagerOptions{}
 	}
 
-	utilruntime.Must(opts.AddToSchemeFunc(scheme))
+	if opts.AddToSchemeFunc != nil {
+		if err := opts.AddToSchemeFunc(scheme); err != nil {
+			return nil, fmt.Errorf("error adding types to scheme: %w", err)
+		}
+	}
+
+	restConfig := opts.RestConfig
+	if restConfig == nil {
+		var err error
+		if restConfig, err = ctrl.GetConfig(); err != nil {
+			return nil, fmt.Errorf("error getting kubeconfig: %w", err)
+		}
+	}
+
+	metricsBindAddress := opts.MetricsBindAddress
+	if metricsBindAddress == "" {
+		metricsBindAddress = "0"
+	}
+
+	cacheOpts, err := cacheOptions(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	crLogger := NewControllerRuntimeLogger()
 	logf.SetLogger(crLogger)
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:           scheme,
-		LeaderElection:   len(opts.LeaderElectionID) > 0,
-		LeaderElectionID: opts.LeaderElectionID,
-		Logger:           crLogger,
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:                  scheme,
+		LeaderElection:          len(opts.LeaderElectionID) > 0,
+		LeaderElectionID:        opts.LeaderElectionID,
+		LeaderElectionNamespace: opts.LeaderElectionNamespace,
+		LeaseDuration:           durationOrNil(opts.LeaseDuration),
+		RenewDeadline:           durationOrNil(opts.RenewDeadline),
+		RetryPeriod:             durationOrNil(opts.RetryPeriod),
+		GracefulShutdownTimeout: durationOrNil(opts.GracefulShutdownTimeout),
+		Logger:                  crLogger,
+		Cache:                   cacheOpts,
 		Metrics: ctrlMetrics.Options{
-			BindAddress: "0",
+			BindAddress: metricsBindAddress,
 		},
+		HealthProbeBindAddress: opts.HealthProbeBindAddress,
+		PprofBindAddress:       opts.PprofBindAddress,
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    opts.WebhookPort,
+			CertDir: opts.WebhookCertDir,
+		}),
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -62,5 +182,14 @@ func Manager(opts *ManagerOptions) (manager.Manager, error) {
 		return nil, fmt.Errorf("error setting up manager: %w", err)
 	}
 
+	if opts.HealthProbeBindAddress != "" {
+		if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+			return nil, fmt.Errorf("error adding health check: %w", err)
+		}
+		if err := mgr.AddReadyzCheck("ping", healthz.Ping); err != nil {
+			return nil, fmt.Errorf("error adding ready check: %w", err)
+		}
+	}
+
 	return mgr, nil
 }
@@ -0,0 +1,68 @@
+package kopper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestManager(t *testing.T) {
+	mgr, err := Manager(&ManagerOptions{
+		RestConfig:     &rest.Config{Host: "http://127.0.0.1:1"},
+		WebhookPort:    9444,
+		WebhookCertDir: t.TempDir(),
+		SyncPeriod:     time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mgr.GetConfig().Host != "http://127.0.0.1:1" {
+		t.Errorf("expected the rest config override to be used, got %q", mgr.GetConfig().Host)
+	}
+}
+
+func TestManagerAddToSchemeError(t *testing.T) {
+	_, err := Manager(&ManagerOptions{
+		RestConfig:      &rest.Config{Host: "http://127.0.0.1:1"},
+		AddToSchemeFunc: func(*runtime.Scheme) error { return errors.New("bad scheme") },
+	})
+	if err == nil || !strings.Contains(err.Error(), "bad scheme") {
+		t.Fatalf("expected scheme error, got %v", err)
+	}
+}
+
+func TestCacheOptionsScopeOnlyScopedObjects(t *testing.T) {
+	obj := &testResource{}
+	cacheOpts, err := cacheOptions(&ManagerOptions{
+		Namespaces:    []string{"default"},
+		LabelSelector: labels.SelectorFromSet(labels.Set{"team": "a"}),
+		ScopedObjects: []client.Object{obj},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cacheOpts.DefaultNamespaces != nil || cacheOpts.DefaultLabelSelector != nil {
+		t.Errorf("expected other kinds to be cached unfiltered, got %+v", cacheOpts)
+	}
+
+	scope, ok := cacheOpts.ByObject[obj]
+	if !ok {
+		t.Fatalf("expected the scoped object to be filtered, got %+v", cacheOpts.ByObject)
+	}
+	if _, ok := scope.Namespaces["default"]; !ok || len(scope.Namespaces) != 1 {
+		t.Errorf("expected the default namespace, got %v", scope.Namespaces)
+	}
+	if !scope.Label.Matches(labels.Set{"team": "a"}) || scope.Label.Matches(labels.Set{"team": "b"}) {
+		t.Errorf("unexpected label selector %v", scope.Label)
+	}
+
+	if _, err := cacheOptions(&ManagerOptions{Namespaces: []string{"default"}}); err == nil {
+		t.Error("expected an error without ScopedObjects")
+	}
+}
@@ -0,0 +1,90 @@
+package kopper
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsActionUpsert   = "upsert"
+	metricsActionDelete   = "delete"
+	metricsActionConflict = "conflict"
+	metricsActionSweep    = "sweep"
+	metricsActionDrift    = "drift"
+
+	metricsResultSuccess   = "success"
+	metricsResultError     = "error"
+	metricsResultPermanent = "permanent"
+	metricsResultIgnored   = "ignored"
+)
+
+var gvkLabels = []string{"group", "version", "kind"}
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopper_reconcile_total",
+		Help: "Total number of upsert, delete, conflict, orphan sweep and drift check callbacks by result",
+	}, append(gvkLabels, "action", "result"))
+
+	callbackDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kopper_callback_duration_seconds",
+		Help:    "Latency of OnUpsertFunc and OnDeleteFunc callbacks",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, append(gvkLabels, "action"))
+
+	statusUpdateFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopper_status_update_failures_total",
+		Help: "Total number of failed status updates",
+	}, gvkLabels)
+
+	malformedResourcesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopper_malformed_resources_total",
+		Help: "Total number of resources that failed conversion to their typed object",
+	}, gvkLabels)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileTotal,
+		callbackDuration,
+		statusUpdateFailuresTotal,
+		malformedResourcesTotal,
+	)
+}
+
+func metricsResult(err error) string {
+	switch {
+	case err == nil:
+		return metricsResultSuccess
+	case isIgnoredError(err):
+		return metricsResultIgnored
+	case isPermanentError(err):
+		return metricsResultPermanent
+	default:
+		return metricsResultError
+	}
+}
+
+func recordCallback(gvk schema.GroupVersionKind, action string, started time.Time, err error) {
+	callbackDuration.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind, action).Observe(time.Since(started).Seconds())
+	recordReconcile(gvk, action, err)
+
+	if action == metricsActionUpsert {
+		upsertStats.record(time.Now(), metricsResult(err) == metricsResultError)
+	}
+}
+
+func recordReconcile(gvk schema.GroupVersionKind, action string, err error) {
+	reconcileTotal.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind, action, metricsResult(err)).Inc()
+}
+
+func recordStatusUpdateFailure(gvk schema.GroupVersionKind) {
+	statusUpdateFailuresTotal.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind).Inc()
+}
+
+func recordMalformedResource(gvk schema.GroupVersionKind) {
+	malformedResourcesTotal.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind).Inc()
+}
@@ -0,0 +1,36 @@
+package kopper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReconcileMetrics(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("metrics-ok"), newTestResource("metrics-failed"))
+
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(reconcileTotal.WithLabelValues(testGVK.Group, testGVK.Version, testGVK.Kind, metricsActionUpsert, result))
+	}
+	successBefore, errorBefore, permanentBefore := counter(metricsResultSuccess), counter(metricsResultError), counter(metricsResultPermanent)
+
+	reconcileTestResource(t, r, "metrics-ok")
+
+	r.OnUpsertFunc = func(context.Context, *testResource) error { return errors.New("connection refused") }
+	reconcileTestResource(t, r, "metrics-failed")
+
+	r.OnUpsertFunc = func(context.Context, *testResource) error { return Permanent(errors.New("invalid")) }
+	reconcileTestResource(t, r, "metrics-failed")
+
+	if got := counter(metricsResultSuccess) - successBefore; got != 1 {
+		t.Errorf("expected 1 successful upsert, got %v", got)
+	}
+	if got := counter(metricsResultError) - errorBefore; got != 1 {
+		t.Errorf("expected 1 failed upsert, got %v", got)
+	}
+	if got := counter(metricsResultPermanent) - permanentBefore; got != 1 {
+		t.Errorf("expected 1 permanently failed upsert, got %v", got)
+	}
+}
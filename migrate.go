@@ -0,0 +1,50 @@
+package kopper
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const ReasonMigrated = "Migrated"
+
+// MigrateUnstructuredFunc rewrites legacy shapes of a resource that no longer
+// converts to the Go type, e.g. a map that became a list.
+// It is given the raw object and modifies it in place.
+type MigrateUnstructuredFunc func(obj map[string]any) error
+
+// migrate runs MigrateUnstructuredFunc on a resource that failed to convert and retries the conversion.
+// It returns the migrated resource, converted into obj.
+func (r *Reconciler[T, PT]) migrate(log objectLogger, raw *unstructured.Unstructured, obj PT, convertErr error) (*unstructured.Unstructured, error) {
+	migrated := raw.DeepCopy()
+	if err := r.MigrateUnstructuredFunc(migrated.Object); err != nil {
+		return nil, fmt.Errorf("%w (migration failed: %v)", convertErr, err)
+	}
+	if equality.Semantic.DeepEqual(raw.Object, migrated.Object) {
+		return nil, convertErr
+	}
+
+	if err := fromUnstructured(migrated.Object, obj); err != nil {
+		return nil, fmt.Errorf("%w (still malformed after migration: %v)", convertErr, err)
+	}
+
+	log.Info(2, "migrated malformed resource")
+	return migrated, nil
+}
+
+// writeBackMigration persists a migrated resource, so it is no longer malformed in the API server.
+func (r *Reconciler[T, PT]) writeBackMigration(ctx gocontext.Context, log objectLogger, migrated *unstructured.Unstructured, obj PT) error {
+	if err := r.Update(ctx, migrated); err != nil {
+		return fmt.Errorf("failed to write back migrated resource: %w", err)
+	}
+
+	// pick up the new resourceVersion
+	if err := fromUnstructured(migrated.Object, obj); err != nil {
+		return err
+	}
+
+	r.Events.Eventf(obj, nil, "Normal", ReasonMigrated, ReasonMigrated, "Migrated %s to the current schema", log.resourceName)
+	return nil
+}
@@ -0,0 +1,196 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// legacyAnnotation marks test resources that are read with the legacy list-shaped spec.message.
+const legacyAnnotation = "test.kopper.io/legacy"
+
+// newLegacyTestReconciler returns a reconciler whose client reads the "legacy" resource
+// with spec.message as a list, as long as it has the legacyAnnotation.
+func newLegacyTestReconciler(t *testing.T) (*testReconciler, *events.FakeRecorder) {
+	t.Helper()
+
+	obj := newTestResource("legacy")
+	obj.Spec.Message = "hello,world"
+	obj.Annotations = map[string]string{legacyAnnotation: "true"}
+
+	r, recorder := newTestReconciler(t, obj)
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Get: func(ctx gocontext.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if err := c.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if raw, ok := obj.(*unstructured.Unstructured); ok {
+				return toLegacyShape(raw)
+			}
+			return nil
+		},
+		List: func(ctx gocontext.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			if err := c.List(ctx, list, opts...); err != nil {
+				return err
+			}
+			if raw, ok := list.(*unstructured.UnstructuredList); ok {
+				for i := range raw.Items {
+					if err := toLegacyShape(&raw.Items[i]); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		Patch: func(ctx gocontext.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			if err := c.Patch(ctx, obj, patch, opts...); err != nil {
+				return err
+			}
+			return readBackLegacyShape(obj)
+		},
+		SubResourcePatch: func(ctx gocontext.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			if err := c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...); err != nil {
+				return err
+			}
+			return readBackLegacyShape(obj)
+		},
+		SubResourceUpdate: func(ctx gocontext.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			if err := c.SubResource(subResourceName).Update(ctx, obj, opts...); err != nil {
+				return err
+			}
+			return readBackLegacyShape(obj)
+		},
+	})
+	return r, recorder
+}
+
+// readBackLegacyShape decodes the legacy shape of a write response into obj,
+// which fails for typed objects like it does with the API server.
+func readBackLegacyShape(obj client.Object) error {
+	if raw, ok := obj.(*unstructured.Unstructured); ok {
+		return toLegacyShape(raw)
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	raw := &unstructured.Unstructured{Object: content}
+	if err := toLegacyShape(raw); err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(raw.Object, obj)
+}
+
+func toLegacyShape(raw *unstructured.Unstructured) error {
+	if raw.GetAnnotations()[legacyAnnotation] == "" {
+		return nil
+	}
+	message, _, _ := unstructured.NestedString(raw.Object, "spec", "message")
+	return unstructured.SetNestedStringSlice(raw.Object, strings.Split(message, ","), "spec", "message")
+}
+
+func migrateTestResource(obj map[string]any) error {
+	messages, found, err := unstructured.NestedStringSlice(obj, "spec", "message")
+	if err != nil || !found {
+		return err
+	}
+	unstructured.RemoveNestedField(obj, "metadata", "annotations", legacyAnnotation)
+	return unstructured.SetNestedField(obj, strings.Join(messages, ","), "spec", "message")
+}
+
+func TestReconcileMigratesMalformedResource(t *testing.T) {
+	for _, writeBack := range []bool{false, true} {
+		r, _ := newLegacyTestReconciler(t)
+		r.MigrateUnstructuredFunc = migrateTestResource
+		r.WriteBackMigrations = writeBack
+
+		var upserted string
+		r.OnUpsertFunc = func(_ context.Context, obj *testResource) error {
+			upserted = obj.Spec.Message
+			return nil
+		}
+
+		_, obj, err := reconcileTestResource(t, r, "legacy")
+		if err != nil {
+			t.Fatalf("writeBack=%v: unexpected error: %v", writeBack, err)
+		}
+		if upserted != "hello,world" {
+			t.Errorf("writeBack=%v: expected OnUpsertFunc to receive the migrated spec, got %q", writeBack, upserted)
+		}
+		if _, legacy := obj.Annotations[legacyAnnotation]; legacy == writeBack {
+			t.Errorf("writeBack=%v: unexpected stored annotations %v", writeBack, obj.Annotations)
+		}
+	}
+}
+
+func TestReconcileMigrationFails(t *testing.T) {
+	r, recorder := newLegacyTestReconciler(t)
+	r.MigrateUnstructuredFunc = func(map[string]any) error { return errors.New("unknown shape") }
+
+	_, obj, err := reconcileTestResource(t, r, "legacy")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorder.Events) != 1 || !strings.Contains(<-recorder.Events, ReasonMalformedResource) {
+		t.Errorf("expected a MalformedResource event")
+	}
+	if c := k8smeta.FindStatusCondition(obj.Status.Conditions, ReadyConditionType); c == nil || !strings.Contains(c.Message, "unknown shape") {
+		t.Errorf("expected the migration error in the Ready condition, got %+v", c)
+	}
+}
+
+func TestReconcileAddsFinalizerWithoutWritingBackMigration(t *testing.T) {
+	r, _ := newLegacyTestReconciler(t)
+	r.MigrateUnstructuredFunc = migrateTestResource
+
+	obj := &testResource{}
+	if err := r.Get(gocontext.Background(), client.ObjectKey{Namespace: "default", Name: "legacy"}, obj); err != nil {
+		t.Fatalf("failed to get legacy: %v", err)
+	}
+	obj.Finalizers = nil
+	if err := r.Update(gocontext.Background(), obj); err != nil {
+		t.Fatalf("failed to remove the finalizer: %v", err)
+	}
+
+	_, obj, err := reconcileTestResource(t, r, "legacy")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(obj.Finalizers) != 1 || obj.Finalizers[0] != r.Finalizer {
+		t.Errorf("expected the finalizer to be added, got %v", obj.Finalizers)
+	}
+	if _, legacy := obj.Annotations[legacyAnnotation]; !legacy {
+		t.Errorf("expected the migrated resource not to be written back, got annotations %v", obj.Annotations)
+	}
+}
+
+func TestReconcileMigratedResourceWritesStatusWithoutWriteBack(t *testing.T) {
+	r, _ := newLegacyTestReconciler(t)
+	r.MigrateUnstructuredFunc = migrateTestResource
+	r.SkipUnchangedSpec = true
+
+	upserts := 0
+	r.OnUpsertFunc = func(context.Context, *testResource) error {
+		upserts++
+		return nil
+	}
+
+	for range 2 {
+		if _, _, err := reconcileTestResource(t, r, "legacy"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if upserts != 1 {
+		t.Errorf("expected the status and spec hash writes to succeed, got %d upserts", upserts)
+	}
+}
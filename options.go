@@ -0,0 +1,419 @@
+package kopper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// Option configures a Reconciler of PT built by SetupReconcilerWithOptions.
+//
+// The options of typed callbacks, e.g. WithOnUpsert, WithOnConflict or WithDriftCheck,
+// are bound to PT, so a callback for another type does not compile.
+// All other options return a SharedOption, which is an Option of every type
+// and can be shared by the reconcilers of several CRDs, see Controllers.
+type Option[PT client.Object] func(*options)
+
+// SharedOption configures a setting that does not depend on the reconciled type.
+// It can be used wherever an Option is expected.
+type SharedOption = func(*options)
+
+type options struct {
+	// onUpsert, onDeleteObject and onConflict hold typed callbacks (OnUpsertFunc[PT], ...).
+	// Option[PT] binds them to the reconciler's PT at compile time, but an Option is
+	// also assignable to SharedOption, so they are asserted again when the Reconciler is built.
+	onUpsert       any
+	onDeleteObject any
+	onConflict     any
+	driftCheck     any
+	validate       any
+	defaulter      any
+	conversions    any
+
+	onDelete          OnDeleteFunc
+	finalizer         string
+	eventRecorderName string
+	controllerName    string
+	requeuePolicy     RequeuePolicy
+	tracerProvider    trace.TracerProvider
+	orphanSweeper     *OrphanSweeperOptions
+	resyncOnStart     bool
+	driftInterval     time.Duration
+	driftMode         DriftMode
+	pauseAnnotation   string
+	predicates        []predicate.Predicate
+	skipUnchangedSpec bool
+	namespaces        []string
+	labelSelector     labels.Selector
+	outOfScopeAction  OutOfScopeAction
+	validatingWebhook bool
+	defaultingWebhook bool
+	migrate           MigrateUnstructuredFunc
+	writeBack         bool
+	maxConcurrent     int
+	rateLimiter       workqueue.TypedRateLimiter[reconcile.Request]
+	reconcileTimeout  time.Duration
+	callbackTimeout   time.Duration
+	logFormat         LogFormat
+}
+
+// WithOnUpsert sets the function called when a resource is created or updated.
+func WithOnUpsert[PT client.Object](fn OnUpsertFunc[PT]) Option[PT] {
+	return func(o *options) {
+		o.onUpsert = fn
+	}
+}
+
+// WithOnDelete sets the function called when a resource is deleted.
+func WithOnDelete(fn OnDeleteFunc) SharedOption {
+	return func(o *options) {
+		o.onDelete = fn
+	}
+}
+
+// WithOnDeleteObject sets the function called with the resource when it is deleted.
+// It takes precedence over WithOnDelete.
+func WithOnDeleteObject[PT client.Object](fn OnDeleteObjectFunc[PT]) Option[PT] {
+	return func(o *options) {
+		o.onDeleteObject = fn
+	}
+}
+
+// WithOnConflict sets the function called when OnUpsertFunc fails with a unique constraint violation.
+func WithOnConflict[PT client.Object](fn OnConflictFunc[PT]) Option[PT] {
+	return func(o *options) {
+		o.onConflict = fn
+	}
+}
+
+// WithFinalizer sets the finalizer added to every reconciled resource.
+func WithFinalizer(finalizer string) SharedOption {
+	return func(o *options) {
+		o.finalizer = finalizer
+	}
+}
+
+// WithEventRecorderName sets the name events are reported by.
+// Defaults to the finalizer.
+func WithEventRecorderName(name string) SharedOption {
+	return func(o *options) {
+		o.eventRecorderName = name
+	}
+}
+
+// WithControllerName sets the name of the controller registered with the manager.
+// Defaults to the lowercase kind of the reconciled resource.
+func WithControllerName(name string) SharedOption {
+	return func(o *options) {
+		o.controllerName = name
+	}
+}
+
+// WithRequeuePolicy sets the policy deciding when failed reconciles are retried.
+func WithRequeuePolicy(policy RequeuePolicy) SharedOption {
+	return func(o *options) {
+		o.requeuePolicy = policy
+	}
+}
+
+// WithTracerProvider sets the OpenTelemetry provider used to trace reconciles.
+// Defaults to the global provider.
+func WithTracerProvider(provider trace.TracerProvider) SharedOption {
+	return func(o *options) {
+		o.tracerProvider = provider
+	}
+}
+
+// WithOrphanSweeper enables the periodic cleanup of database records whose resource no longer exists.
+func WithOrphanSweeper(sweeper OrphanSweeperOptions) SharedOption {
+	return func(o *options) {
+		o.orphanSweeper = &sweeper
+	}
+}
+
+// WithStartupResync enqueues every resource once after the cache has synced
+// and leader election is won, so that all resources are upserted again.
+func WithStartupResync() SharedOption {
+	return func(o *options) {
+		o.resyncOnStart = true
+	}
+}
+
+// WithDriftCheck calls fn for every resource each interval and handles drifted resources according to mode.
+func WithDriftCheck[PT client.Object](fn DriftCheckFunc[PT], interval time.Duration, mode DriftMode) Option[PT] {
+	return func(o *options) {
+		o.driftCheck = fn
+		o.driftInterval = interval
+		o.driftMode = mode
+	}
+}
+
+// WithPauseAnnotation sets the annotation that suspends reconciliation of a resource when set to "true".
+// Defaults to DefaultPauseAnnotation.
+func WithPauseAnnotation(annotation string) SharedOption {
+	return func(o *options) {
+		o.pauseAnnotation = annotation
+	}
+}
+
+// WithPredicates adds predicates filtering the watch events of the reconciled resource.
+// They are combined with DefaultPredicate.
+func WithPredicates(predicates ...predicate.Predicate) SharedOption {
+	return func(o *options) {
+		o.predicates = append(o.predicates, predicates...)
+	}
+}
+
+// WithSkipUnchangedSpec skips OnUpsertFunc when the spec and generation
+// match the last successful upsert, e.g. on operator restarts.
+func WithSkipUnchangedSpec() SharedOption {
+	return func(o *options) {
+		o.skipUnchangedSpec = true
+	}
+}
+
+// WithNamespaces only reconciles resources in the given namespaces.
+func WithNamespaces(namespaces ...string) SharedOption {
+	return func(o *options) {
+		o.namespaces = append(o.namespaces, namespaces...)
+	}
+}
+
+// WithLabelSelector only reconciles resources matching the selector.
+func WithLabelSelector(selector labels.Selector) SharedOption {
+	return func(o *options) {
+		o.labelSelector = selector
+	}
+}
+
+// WithOutOfScopeAction sets how resources that move out of the namespaces
+// or label selector are released. Defaults to OutOfScopeOrphan.
+func WithOutOfScopeAction(action OutOfScopeAction) SharedOption {
+	return func(o *options) {
+		o.outOfScopeAction = action
+	}
+}
+
+// WithValidatingWebhook registers a validating webhook for the reconciled kind,
+// which rejects malformed resources and those failing fn. fn may be nil.
+func WithValidatingWebhook[PT client.Object](fn ValidateFunc[PT]) Option[PT] {
+	return func(o *options) {
+		o.validatingWebhook = true
+		if fn != nil {
+			o.validate = fn
+		}
+	}
+}
+
+// WithDefaulter sets the function that fills in defaults before OnUpsertFunc is called.
+func WithDefaulter[PT client.Object](fn DefaultFunc[PT]) Option[PT] {
+	return func(o *options) {
+		o.defaulter = fn
+	}
+}
+
+// WithDefaultingWebhook sets fn as with WithDefaulter and also registers it
+// as a mutating webhook for the reconciled kind.
+func WithDefaultingWebhook[PT client.Object](fn DefaultFunc[PT]) Option[PT] {
+	return func(o *options) {
+		o.defaulter = fn
+		o.defaultingWebhook = true
+	}
+}
+
+// WithConversion serves a conversion webhook for the reconciled kind, with the reconciled type as the hub.
+// Spokes are built with conversion.NewSpokeConverter, e.g.
+//
+//	kopper.WithConversion(conversion.NewSpokeConverter(&v1.Widget{}, v2ToV1, v1ToV2))
+func WithConversion[PT client.Object](spokes ...conversion.SpokeConverter[PT]) Option[PT] {
+	return func(o *options) {
+		o.conversions = spokes
+	}
+}
+
+// WithMigrateUnstructured repairs resources that fail to convert to the reconciled type with fn.
+// When writeBack is set, repaired resources are updated in the API server.
+func WithMigrateUnstructured(fn MigrateUnstructuredFunc, writeBack bool) SharedOption {
+	return func(o *options) {
+		o.migrate = fn
+		o.writeBack = writeBack
+	}
+}
+
+// WithMaxConcurrentReconciles sets the number of resources reconciled in parallel.
+func WithMaxConcurrentReconciles(n int) SharedOption {
+	return func(o *options) {
+		o.maxConcurrent = n
+	}
+}
+
+// WithRateLimiter sets the workqueue rate limiter, e.g. one built with NewRateLimiter.
+func WithRateLimiter(limiter workqueue.TypedRateLimiter[reconcile.Request]) SharedOption {
+	return func(o *options) {
+		o.rateLimiter = limiter
+	}
+}
+
+// WithReconcileTimeout cancels the context passed to the callbacks once a reconcile takes longer than timeout.
+func WithReconcileTimeout(timeout time.Duration) SharedOption {
+	return func(o *options) {
+		o.reconcileTimeout = timeout
+	}
+}
+
+// WithCallbackTimeout cancels the context passed to each callback once the call takes longer than timeout.
+func WithCallbackTimeout(timeout time.Duration) SharedOption {
+	return func(o *options) {
+		o.callbackTimeout = timeout
+	}
+}
+
+// WithLogFormat sets how reconciled resources are logged.
+func WithLogFormat(format LogFormat) SharedOption {
+	return func(o *options) {
+		o.logFormat = format
+	}
+}
+
+// typedOption asserts that an option value set through a generic With* function
+// matches the callback type expected by the reconciler. It only fails for typed
+// options passed as a SharedOption, e.g. in Controllers.Options.
+func typedOption[F any](name string, v any) (F, error) {
+	var zero F
+	if v == nil {
+		return zero, nil
+	}
+
+	fn, ok := v.(F)
+	if !ok {
+		return zero, fmt.Errorf("%s has type %T, expected %T", name, v, zero)
+	}
+	return fn, nil
+}
+
+// SetupReconcilerWithOptions builds a Reconciler from the given options and registers it with the manager.
+func SetupReconcilerWithOptions[T any, PT interface {
+	*T
+	client.Object
+}](ctx context.Context, mgr ctrl.Manager, opts ...Option[PT]) (*Reconciler[T, PT], error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.finalizer == "" {
+		return nil, fmt.Errorf("field Finalizer cannot be empty")
+	}
+
+	onUpsert, err := typedOption[OnUpsertFunc[PT]]("OnUpsertFunc", o.onUpsert)
+	if err != nil {
+		return nil, err
+	} else if onUpsert == nil {
+		return nil, fmt.Errorf("field OnUpsertFunc cannot be empty")
+	}
+
+	onDeleteObject, err := typedOption[OnDeleteObjectFunc[PT]]("OnDeleteObjectFunc", o.onDeleteObject)
+	if err != nil {
+		return nil, err
+	} else if onDeleteObject == nil && o.onDelete == nil {
+		return nil, fmt.Errorf("field OnDeleteFunc or OnDeleteObjectFunc cannot be empty")
+	}
+
+	onConflict, err := typedOption[OnConflictFunc[PT]]("OnConflictFunc", o.onConflict)
+	if err != nil {
+		return nil, err
+	}
+
+	driftCheck, err := typedOption[DriftCheckFunc[PT]]("DriftCheckFunc", o.driftCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	validate, err := typedOption[ValidateFunc[PT]]("ValidateFunc", o.validate)
+	if err != nil {
+		return nil, err
+	}
+
+	defaulter, err := typedOption[DefaultFunc[PT]]("DefaultFunc", o.defaulter)
+	if err != nil {
+		return nil, err
+	}
+
+	spokes, err := typedOption[[]conversion.SpokeConverter[PT]]("SpokeConverters", o.conversions)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.outOfScopeAction {
+	case "", OutOfScopeOrphan, OutOfScopeDelete:
+	default:
+		return nil, fmt.Errorf("unknown out of scope action %q", o.outOfScopeAction)
+	}
+
+	switch o.logFormat {
+	case "", LogFormatStructured, LogFormatText:
+	default:
+		return nil, fmt.Errorf("unknown log format %q", o.logFormat)
+	}
+
+	if o.eventRecorderName == "" {
+		o.eventRecorderName = o.finalizer
+	}
+
+	r := &Reconciler[T, PT]{
+		DutyContext:        ctx,
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		OnUpsertFunc:       onUpsert,
+		OnDeleteFunc:       o.onDelete,
+		OnDeleteObjectFunc: onDeleteObject,
+		OnConflictFunc:     onConflict,
+		Finalizer:          o.finalizer,
+		ControllerName:     o.controllerName,
+		Events:             mgr.GetEventRecorder(o.eventRecorderName),
+		RequeuePolicy:      o.requeuePolicy,
+		OrphanSweeper:      o.orphanSweeper,
+		ResyncOnStart:      o.resyncOnStart,
+		DriftCheckFunc:     driftCheck,
+		DriftCheckInterval: o.driftInterval,
+		DriftMode:          o.driftMode,
+		PauseAnnotation:    o.pauseAnnotation,
+		Predicates:         o.predicates,
+		SkipUnchangedSpec:  o.skipUnchangedSpec,
+		Namespaces:         o.namespaces,
+		LabelSelector:      o.labelSelector,
+		OutOfScopeAction:   o.outOfScopeAction,
+		ValidatingWebhook:  o.validatingWebhook,
+		ValidateFunc:       validate,
+		DefaultFunc:        defaulter,
+		DefaultingWebhook:  o.defaultingWebhook,
+		SpokeConverters:    spokes,
+
+		MigrateUnstructuredFunc: o.migrate,
+		WriteBackMigrations:     o.writeBack,
+		MaxConcurrentReconciles: o.maxConcurrent,
+		RateLimiter:             o.rateLimiter,
+		ReconcileTimeout:        o.reconcileTimeout,
+		CallbackTimeout:         o.callbackTimeout,
+		LogFormat:               o.logFormat,
+	}
+
+	if o.tracerProvider != nil {
+		r.Tracer = o.tracerProvider.Tracer(tracerName)
+	}
+
+	if err := r.SetupWithManager(mgr); err != nil {
+		return nil, fmt.Errorf("error setting up manager: %w", err)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,58 @@
+package kopper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTypedOption(t *testing.T) {
+	onUpsert := OnUpsertFunc[*corev1.ConfigMap](func(context.Context, *corev1.ConfigMap) error { return nil })
+
+	fn, err := typedOption[OnUpsertFunc[*corev1.ConfigMap]]("OnUpsertFunc", onUpsert)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fn == nil {
+		t.Fatal("expected typed function to be returned")
+	}
+
+	fn, err = typedOption[OnUpsertFunc[*corev1.ConfigMap]]("OnUpsertFunc", nil)
+	if err != nil || fn != nil {
+		t.Fatalf("expected nil function without error, got %v, %v", fn, err)
+	}
+
+	_, err = typedOption[OnUpsertFunc[*corev1.Secret]]("OnUpsertFunc", onUpsert)
+	if err == nil || !strings.Contains(err.Error(), "OnUpsertFunc has type") {
+		t.Fatalf("expected type mismatch error, got %v", err)
+	}
+}
+
+func TestSetupReconcilerWithOptionsValidation(t *testing.T) {
+	onUpsert := func(context.Context, *corev1.ConfigMap) error { return nil }
+
+	tests := []struct {
+		name    string
+		opts    []Option[*corev1.ConfigMap]
+		wantErr string
+	}{
+		{"missing finalizer", []Option[*corev1.ConfigMap]{WithOnUpsert(onUpsert)}, "field Finalizer cannot be empty"},
+		{"missing upsert", []Option[*corev1.ConfigMap]{WithFinalizer("test.kopper.io")}, "field OnUpsertFunc cannot be empty"},
+		{"missing delete", []Option[*corev1.ConfigMap]{WithFinalizer("test.kopper.io"), WithOnUpsert(onUpsert)}, "field OnDeleteFunc or OnDeleteObjectFunc cannot be empty"},
+		{"mismatched shared upsert", []Option[*corev1.ConfigMap]{
+			WithFinalizer("test.kopper.io"),
+			SharedOption(WithOnUpsert(func(context.Context, *corev1.Secret) error { return nil })),
+		}, "OnUpsertFunc has type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SetupReconcilerWithOptions[corev1.ConfigMap](context.New(), nil, tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
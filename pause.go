@@ -0,0 +1,66 @@
+package kopper
+
+import (
+	gocontext "context"
+
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultPauseAnnotation suspends reconciliation of a resource when set to "true".
+const DefaultPauseAnnotation = "kopper.flanksource.com/paused"
+
+const (
+	PausedConditionType = "Paused"
+
+	ReasonPaused  = "Paused"
+	ReasonResumed = "Resumed"
+)
+
+func (r *Reconciler[T, PT]) pauseAnnotation() string {
+	if r.PauseAnnotation != "" {
+		return r.PauseAnnotation
+	}
+	return DefaultPauseAnnotation
+}
+
+func (r *Reconciler[T, PT]) isPaused(obj PT) bool {
+	return obj.GetAnnotations()[r.pauseAnnotation()] == "true"
+}
+
+func (r *Reconciler[T, PT]) isConditionTrue(obj PT, conditionType string) bool {
+	conditioner, ok := any(obj).(StatusConditioner)
+	if !ok || conditioner.GetStatusConditions() == nil {
+		return false
+	}
+	return k8smeta.IsStatusConditionTrue(*conditioner.GetStatusConditions(), conditionType)
+}
+
+// pause records that reconciliation of obj is suspended.
+func (r *Reconciler[T, PT]) pause(ctx gocontext.Context, log objectLogger, obj PT, original runtime.Object) error {
+	if r.isConditionTrue(obj, PausedConditionType) {
+		return nil
+	}
+
+	log.Info(2, "reconciliation paused")
+	r.Events.Eventf(obj, nil, "Normal", ReasonPaused, ReasonPaused, "Reconciliation of %s is paused", log.resourceName)
+
+	message := "Reconciliation is paused by the " + r.pauseAnnotation() + " annotation"
+	if !r.setConditionType(obj, PausedConditionType, metav1.ConditionTrue, ReasonPaused, message) {
+		return nil
+	}
+	return r.updateStatus(ctx, log, obj, original)
+}
+
+// resume clears the Paused condition once the pause annotation is removed.
+// The status is written along with the rest of the reconcile.
+func (r *Reconciler[T, PT]) resume(log objectLogger, obj PT) {
+	if !r.isConditionTrue(obj, PausedConditionType) {
+		return
+	}
+
+	log.Info(2, "reconciliation resumed")
+	r.Events.Eventf(obj, nil, "Normal", ReasonResumed, ReasonResumed, "Reconciliation of %s is resumed", log.resourceName)
+	r.setConditionType(obj, PausedConditionType, metav1.ConditionFalse, ReasonResumed, "")
+}
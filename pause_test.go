@@ -0,0 +1,60 @@
+package kopper
+
+import (
+	gocontext "context"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcilePaused(t *testing.T) {
+	obj := newTestResource("paused")
+	obj.Finalizers = nil
+	obj.Annotations = map[string]string{DefaultPauseAnnotation: "true"}
+
+	r, recorder := newTestReconciler(t, obj)
+
+	upserts := 0
+	r.OnUpsertFunc = func(context.Context, *testResource) error {
+		upserts++
+		return nil
+	}
+
+	for range 2 {
+		if _, _, err := reconcileTestResource(t, r, "paused"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	_, obj, _ = reconcileTestResource(t, r, "paused")
+	if upserts != 0 {
+		t.Errorf("expected OnUpsertFunc to be skipped while paused, got %d calls", upserts)
+	}
+	if len(obj.Finalizers) != 1 {
+		t.Errorf("expected the finalizer to be added while paused, got %v", obj.Finalizers)
+	}
+	if c := k8smeta.FindStatusCondition(obj.Status.Conditions, PausedConditionType); c == nil || c.Status != metav1.ConditionTrue {
+		t.Errorf("expected Paused=True, got %+v", c)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a single Paused event, got %d", len(recorder.Events))
+	}
+
+	obj.Annotations = nil
+	if err := r.Update(gocontext.Background(), obj); err != nil {
+		t.Fatalf("failed to remove pause annotation: %v", err)
+	}
+
+	_, obj, err := reconcileTestResource(t, r, "paused")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if upserts != 1 {
+		t.Errorf("expected OnUpsertFunc to be called after resuming, got %d calls", upserts)
+	}
+	if c := k8smeta.FindStatusCondition(obj.Status.Conditions, PausedConditionType); c == nil || c.Status != metav1.ConditionFalse || c.Reason != ReasonResumed {
+		t.Errorf("expected Paused=False/%s, got %+v", ReasonResumed, c)
+	}
+}
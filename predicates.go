@@ -0,0 +1,49 @@
+package kopper
+
+import (
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// DefaultPredicate filters out updates that only touch the status or unrelated metadata,
+// such as the status patches written by kopper itself.
+// Updates pass when the generation, annotations, finalizers or deletion timestamp change,
+// and on the periodic resyncs of the informer, see ManagerOptions.SyncPeriod.
+func DefaultPredicate() predicate.Predicate {
+	return predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		predicate.AnnotationChangedPredicate{},
+		finalizersChangedPredicate(),
+		resyncPredicate(),
+	)
+}
+
+// resyncPredicate passes the updates the informer sends on resync, which carry an unchanged object.
+func resyncPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			return e.ObjectNew.GetResourceVersion() != "" && e.ObjectOld.GetResourceVersion() == e.ObjectNew.GetResourceVersion()
+		},
+	}
+}
+
+// finalizersChangedPredicate passes updates that change the finalizers or the deletion timestamp.
+func finalizersChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+
+			if !e.ObjectOld.GetDeletionTimestamp().Equal(e.ObjectNew.GetDeletionTimestamp()) {
+				return true
+			}
+			return !slices.Equal(e.ObjectOld.GetFinalizers(), e.ObjectNew.GetFinalizers())
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package kopper
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestDefaultPredicate(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(*testResource)
+		expected bool
+	}{
+		{"status only", func(o *testResource) { o.Status.ObservedGeneration = 1 }, false},
+		{"labels only", func(o *testResource) { o.Labels = map[string]string{"app": "kopper"} }, false},
+		{"generation", func(o *testResource) { o.Generation = 2 }, true},
+		{"annotations", func(o *testResource) { o.Annotations = map[string]string{DefaultPauseAnnotation: "true"} }, true},
+		{"finalizers", func(o *testResource) { o.Finalizers = nil }, true},
+		{"deletion", func(o *testResource) { o.DeletionTimestamp = &metav1.Time{Time: time.Now()} }, true},
+		{"resync", func(o *testResource) { o.ResourceVersion = "1" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldObj := newTestResource("resource")
+			oldObj.ResourceVersion = "1"
+			newObj := oldObj.DeepCopyObject().(*testResource)
+			newObj.ResourceVersion = "2"
+			tt.mutate(newObj)
+
+			if got := DefaultPredicate().Update(event.UpdateEvent{ObjectOld: oldObj, ObjectNew: newObj}); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if !DefaultPredicate().Create(event.CreateEvent{Object: newTestResource("resource")}) {
+		t.Error("expected create events to pass")
+	}
+}
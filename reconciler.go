@@ -2,6 +2,7 @@ package kopper
 
 import (
 	gocontext "context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -11,17 +12,30 @@ import (
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
 )
 
 // Custom Resources that uses "status" subresource
@@ -36,6 +50,17 @@ const (
 	ReasonSynced        = "Synced"
 	ReasonPersistFailed = "PersistFailed"
 	ReasonDeleteFailed  = "DeleteFailed"
+
+	// ReasonMalformedResource is reported when a resource does not match the Go type definition.
+	ReasonMalformedResource = "MalformedResource"
+
+	// ReasonPermanentFailure is set when a callback returned a PermanentError.
+	// The resource is not reconciled again until its generation changes.
+	ReasonPermanentFailure = "PermanentFailure"
+
+	// ReasonPermanentDeleteFailure is set when OnDeleteFunc returned a PermanentError.
+	// The deletion is not retried until the generation of the resource changes.
+	ReasonPermanentDeleteFailure = "PermanentDeleteFailure"
 )
 
 // StatusConditioner allows a CRD to expose its status conditions slice so
@@ -64,35 +89,33 @@ type OnUpsertFunc[PT client.Object] func(context.Context, PT) error
 // OnDeleteFunc is a function that is called when a resource is deleted
 type OnDeleteFunc func(context.Context, string) error
 
+// OnDeleteObjectFunc is a function that is called when a resource is deleted,
+// with the resource as it was when its deletion began, or only its metadata if the resource is malformed.
+// When set, it is called instead of OnDeleteFunc.
+type OnDeleteObjectFunc[PT client.Object] func(context.Context, PT) error
+
 // OnConflictFunc is called when a CRD already exists in the database with a different uid.
 // It is responsible in identifying the corresponding existing record as PT & deleting it
 // so the new resource can be created.
 type OnConflictFunc[PT client.Object] func(context.Context, PT) error
 
+// SetupReconciler registers a Reconciler with the manager.
+// It is kept for existing callers; new options are only exposed through SetupReconcilerWithOptions.
 func SetupReconciler[T any, PT interface {
 	*T
 	client.Object
 }](ctx context.Context, mgr ctrl.Manager, onUpsert OnUpsertFunc[PT], onDelete OnDeleteFunc, onConflict OnConflictFunc[PT], finalizer string) (Reconciler[T, PT], error) {
-	if finalizer == "" {
-		return Reconciler[T, PT]{}, fmt.Errorf("field Finalizer cannot be empty")
-	}
-
-	r := Reconciler[T, PT]{
-		DutyContext:    ctx,
-		Client:         mgr.GetClient(),
-		Scheme:         mgr.GetScheme(),
-		OnUpsertFunc:   onUpsert,
-		OnDeleteFunc:   onDelete,
-		OnConflictFunc: onConflict,
-		Finalizer:      finalizer,
-		Events:         mgr.GetEventRecorder(finalizer),
-	}
-
-	if err := r.SetupWithManager(mgr); err != nil {
-		return Reconciler[T, PT]{}, fmt.Errorf("error setting up manager: %w", err)
+	r, err := SetupReconcilerWithOptions[T, PT](ctx, mgr,
+		WithOnUpsert(onUpsert),
+		WithOnDelete(onDelete),
+		WithOnConflict(onConflict),
+		WithFinalizer(finalizer),
+	)
+	if err != nil {
+		return Reconciler[T, PT]{}, err
 	}
 
-	return r, nil
+	return *r, nil
 }
 
 type Reconciler[T any, PT interface {
@@ -106,8 +129,109 @@ type Reconciler[T any, PT interface {
 	OnDeleteFunc   OnDeleteFunc
 	OnConflictFunc OnConflictFunc[PT]
 	Finalizer      string
+	ControllerName string
 	Events         events.EventRecorder
-	gvk            schema.GroupVersionKind
+
+	// OnDeleteObjectFunc, when set, is called instead of OnDeleteFunc.
+	// Malformed resources are passed with only their metadata, as their spec can't be converted to PT.
+	OnDeleteObjectFunc OnDeleteObjectFunc[PT]
+
+	// RequeuePolicy decides when failed reconciles are retried.
+	// When nil, failures are requeued after fixed delays.
+	RequeuePolicy RequeuePolicy
+
+	// Tracer creates the spans of each reconcile.
+	// Defaults to a tracer from the global OpenTelemetry provider.
+	Tracer trace.Tracer
+
+	// OrphanSweeper, when set, periodically deletes database records whose
+	// resource no longer exists in the cluster.
+	OrphanSweeper *OrphanSweeperOptions
+
+	// DriftCheckFunc, when set, is called for every resource each DriftCheckInterval.
+	// Drifted resources are handled according to DriftMode, which defaults to DriftModeReport.
+	DriftCheckFunc     DriftCheckFunc[PT]
+	DriftCheckInterval time.Duration
+	DriftMode          DriftMode
+
+	// PauseAnnotation suspends reconciliation of a resource when set to "true".
+	// Deletions are still handled. Defaults to DefaultPauseAnnotation.
+	PauseAnnotation string
+
+	// Predicates filter the watch events of the reconciled resource,
+	// in addition to DefaultPredicate.
+	Predicates []predicate.Predicate
+
+	// SkipUnchangedSpec skips OnUpsertFunc when the spec and generation match the last successful upsert.
+	// The spec hash is kept in the status of SpecHashRecorders, or in the SpecHashAnnotation.
+	// Resync, drift repair and the ForceResyncAnnotation bypass the check.
+	SkipUnchangedSpec bool
+
+	// ResyncOnStart enqueues every resource once the cache has synced
+	// and leader election is won.
+	ResyncOnStart bool
+
+	// Namespaces and LabelSelector limit the resources that are reconciled.
+	// Resources that move out of scope are released according to OutOfScopeAction,
+	// which defaults to OutOfScopeOrphan, if they carry the ScopeAnnotation of this reconciler.
+	Namespaces       []string
+	LabelSelector    labels.Selector
+	OutOfScopeAction OutOfScopeAction
+
+	// ValidatingWebhook registers a validating webhook on ValidatePath for the reconciled kind.
+	// It rejects malformed resources and those failing ValidateFunc, if set.
+	ValidatingWebhook bool
+	ValidateFunc      ValidateFunc[PT]
+
+	// DefaultFunc, when set, fills in defaults before every OnUpsertFunc call.
+	// DefaultingWebhook also registers it as a mutating webhook on MutatePath.
+	DefaultFunc       DefaultFunc[PT]
+	DefaultingWebhook bool
+
+	// SpokeConverters convert the other versions of the kind to and from T, the hub version,
+	// through a conversion webhook on /convert. Every version in the scheme needs one.
+	SpokeConverters []conversion.SpokeConverter[PT]
+
+	// MigrateUnstructuredFunc, when set, repairs resources that fail to convert to PT.
+	// With WriteBackMigrations, repaired resources are also updated in the API server.
+	MigrateUnstructuredFunc MigrateUnstructuredFunc
+	WriteBackMigrations     bool
+
+	// MaxConcurrentReconciles is the number of resources reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+
+	// RateLimiter limits how fast failed resources are retried, see NewRateLimiter.
+	// Defaults to the controller-runtime rate limiter.
+	RateLimiter workqueue.TypedRateLimiter[reconcile.Request]
+
+	// ReconcileTimeout cancels the context passed to the callbacks once a reconcile takes longer.
+	ReconcileTimeout time.Duration
+
+	// CallbackTimeout cancels the context passed to each callback once the call takes longer.
+	// Callbacks that do not return once their context is done are abandoned with ErrCallbackAbandoned.
+	CallbackTimeout time.Duration
+
+	// LogFormat selects how reconciled resources are logged. Defaults to LogFormatStructured.
+	LogFormat LogFormat
+
+	gvk          schema.GroupVersionKind
+	attempts     *attemptTracker
+	resyncEvents chan event.GenericEvent
+
+	// elected is closed once the manager is started and this replica won the leader election.
+	elected <-chan struct{}
+
+	// forcedResyncs are resources whose next reconcile runs OnUpsertFunc
+	// even if SkipUnchangedSpec would skip it.
+	forcedResyncs *keySet
+
+	// cache reads the resources listed by the sweeper, resync, drift check and MalformedResources
+	// from the informer of the watch. Falls back to the Client when not set up with a manager.
+	cache client.Reader
+
+	// apiReader reads from the API server without the cache, whose scope may be limited
+	// by ManagerOptions. It is only set up with a manager.
+	apiReader client.Reader
 }
 
 func (r *Reconciler[T, PT]) syncObservedGeneration(obj PT) bool {
@@ -126,25 +250,60 @@ func (r *Reconciler[T, PT]) isObservedGenerationOutdated(obj PT) bool {
 	return getter.GetObservedGeneration() != obj.GetGeneration()
 }
 
-func (r *Reconciler[T, PT]) updateStatus(ctx gocontext.Context, resourceName string, obj PT, original runtime.Object) error {
+// updateStatus writes the status of obj with the patch of its StatusPatchGenerator,
+// or a merge patch guarded by the resourceVersion otherwise.
+// The response is read as unstructured, so a spec migrated in memory does not
+// fail to decode the stored resource returned by the API server.
+func (r *Reconciler[T, PT]) updateStatus(ctx gocontext.Context, log objectLogger, obj PT, original runtime.Object) (err error) {
+	ctx, span := r.startSpan(ctx, spanUpdateStatus, obj)
+	defer func() {
+		if err != nil {
+			recordStatusUpdateFailure(r.gvk)
+			log.WithError(err).Error("failed to update status")
+		}
+		endSpan(span, err)
+	}()
+
+	var patch client.Patch
 	if mgr, ok := any(obj).(StatusPatchGenerator); ok {
-		if patch := mgr.GenerateStatusPatch(original); patch != nil {
-			if err := r.Status().Patch(ctx, obj, patch); err != nil {
-				klog.Errorf("[kopper] failed to update status %s: %v", resourceName, err)
-				return err
-			}
+		if patch = mgr.GenerateStatusPatch(original); patch == nil {
+			return nil
 		}
 	} else {
-		if err := r.Status().Update(ctx, obj); err != nil {
-			klog.Errorf("[kopper] failed to update status %s: %v", resourceName, err)
-			return err
-		}
+		// obj may have been patched since original was copied, e.g. with the finalizer
+		base := original.DeepCopyObject().(client.Object)
+		base.SetResourceVersion(obj.GetResourceVersion())
+		patch = client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})
+	}
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
 	}
 
+	patched := r.unstructuredRef(obj)
+	if err := r.Status().Patch(ctx, patched, client.RawPatch(patch.Type(), data)); err != nil {
+		return err
+	}
+	obj.SetResourceVersion(patched.GetResourceVersion())
 	return nil
 }
 
+// unstructuredRef returns an unstructured object naming obj, to read back API server responses
+// for resources that may not convert to PT.
+func (r *Reconciler[T, PT]) unstructuredRef(obj client.Object) *unstructured.Unstructured {
+	ref := &unstructured.Unstructured{}
+	ref.SetGroupVersionKind(r.gvk)
+	ref.SetNamespace(obj.GetNamespace())
+	ref.SetName(obj.GetName())
+	return ref
+}
+
 func (r *Reconciler[T, PT]) setCondition(obj PT, status metav1.ConditionStatus, reason, message string) bool {
+	return r.setConditionType(obj, ReadyConditionType, status, reason, message)
+}
+
+func (r *Reconciler[T, PT]) setConditionType(obj PT, conditionType string, status metav1.ConditionStatus, reason, message string) bool {
 	conditioner, ok := any(obj).(StatusConditioner)
 	if !ok {
 		return false
@@ -156,7 +315,7 @@ func (r *Reconciler[T, PT]) setCondition(obj PT, status metav1.ConditionStatus,
 	}
 
 	k8smeta.SetStatusCondition(conditions, metav1.Condition{
-		Type:               ReadyConditionType,
+		Type:               conditionType,
 		Status:             status,
 		Reason:             reason,
 		Message:            message,
@@ -167,18 +326,114 @@ func (r *Reconciler[T, PT]) setCondition(obj PT, status metav1.ConditionStatus,
 	return true
 }
 
+// requeue computes the result of a failed reconcile.
+//
+// Without a RequeuePolicy the error is returned along with a requeue after fallback.
+// Otherwise the policy's result is used; the error has already been logged and
+// is only returned when the policy leaves the retry to controller-runtime.
+func (r *Reconciler[T, PT]) requeue(req ctrl.Request, obj client.Object, err error, fallback time.Duration) (ctrl.Result, error) {
+	attempt := r.attempts.failed(req.NamespacedName)
+	if r.RequeuePolicy == nil {
+		return ctrl.Result{Requeue: true, RequeueAfter: fallback}, err
+	}
+
+	result := r.RequeuePolicy.Requeue(err, attempt, obj)
+	if result.IsZero() {
+		return result, err
+	}
+	return result, nil
+}
+
+// isPermanentlyFailed reports whether the current generation was already rejected with a PermanentError,
+// recorded with reason, i.e. ReasonPermanentFailure or ReasonPermanentDeleteFailure.
+func (r *Reconciler[T, PT]) isPermanentlyFailed(obj PT, reason string) bool {
+	conditioner, ok := any(obj).(StatusConditioner)
+	if !ok {
+		return false
+	}
+
+	conditions := conditioner.GetStatusConditions()
+	if conditions == nil {
+		return false
+	}
+
+	ready := k8smeta.FindStatusCondition(*conditions, ReadyConditionType)
+	return ready != nil &&
+		ready.Status == metav1.ConditionFalse &&
+		ready.Reason == reason &&
+		ready.ObservedGeneration == obj.GetGeneration()
+}
+
+// fail records a failed callback on the Ready condition and computes when to retry.
+//
+// PermanentErrors are recorded with ReasonPermanentFailure, or ReasonPermanentDeleteFailure
+// for deletes, and not requeued,
+// RetryAfterErrors with a positive delay are requeued after it and all other errors
+// go through the RequeuePolicy.
+func (r *Reconciler[T, PT]) fail(ctx gocontext.Context, req ctrl.Request, log objectLogger, obj PT, original runtime.Object, reason string, err error, fallback time.Duration) (ctrl.Result, error) {
+	if errors.Is(err, ErrCallbackAbandoned) {
+		// the callback may still be modifying obj
+		return ctrl.Result{}, err
+	}
+
+	permanent := isPermanentError(err)
+	if permanent {
+		reason = lo.Ternary(reason == ReasonDeleteFailed, ReasonPermanentDeleteFailure, ReasonPermanentFailure)
+	}
+
+	var statusErr error
+	if r.setCondition(obj, metav1.ConditionFalse, reason, err.Error()) || r.syncObservedGeneration(obj) {
+		if statusErr = r.updateStatus(ctx, log, obj, original); statusErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to update status for %s: %w", log.resourceName, statusErr))
+		}
+	}
+
+	var retryErr *RetryAfterError
+	switch {
+	case permanent && statusErr == nil:
+		r.attempts.reset(req.NamespacedName)
+		r.Events.Eventf(obj, nil, "Warning", reason, reason, "%v", err)
+		return ctrl.Result{}, nil
+	case errors.As(err, &retryErr) && retryErr.After > 0 && statusErr == nil:
+		r.attempts.failed(req.NamespacedName)
+		return ctrl.Result{RequeueAfter: retryErr.After}, nil
+	}
+
+	return r.requeue(req, obj, err, fallback)
+}
+
 func (r *Reconciler[T, PT]) Reconcile(ctx gocontext.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := r.tracer().Start(ctx, spanReconcile, trace.WithAttributes(
+		attribute.String("k8s.gvk", r.gvk.String()),
+		attribute.String("k8s.namespace", req.Namespace),
+		attribute.String("k8s.name", req.Name),
+	))
+
+	result, err := r.reconcile(ctx, req)
+	if err == nil && result.IsZero() {
+		// keep a forced resync for the retry, but not past a reconcile that skipped or finished the upsert
+		r.forcedResyncs.remove(req.NamespacedName)
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+func (r *Reconciler[T, PT]) reconcile(ctx gocontext.Context, req ctrl.Request) (ctrl.Result, error) {
 	raw := &unstructured.Unstructured{}
 	raw.SetGroupVersionKind(r.gvk)
 
 	if err := r.Get(ctx, req.NamespacedName, raw); err != nil {
 		if apiErrors.IsNotFound(err) {
+			r.attempts.reset(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
 	}
 
+	trace.SpanFromContext(ctx).SetAttributes(spanAttributes(r.gvk, raw)...)
+
 	resourceName := fmt.Sprintf("%s[%s/%s:%s]", r.gvk.Kind, req.Namespace, req.Name, raw.GetUID())
+	log := r.objectLogger(ctx, resourceName, raw)
 
 	klog.SetLogLevel(computeKopperLogLevel(
 		r.DutyContext.Properties().On(false, "kopper.logs"),
@@ -186,86 +441,238 @@ func (r *Reconciler[T, PT]) Reconcile(ctx gocontext.Context, req ctrl.Request) (
 	))
 
 	obj := PT(new(T))
-	if err := fromUnstructured(raw.Object, obj); err != nil {
-		klog.Errorf("[kopper] malformed resource %s: %v", resourceName, err)
-		r.Events.Eventf(raw, nil, "Warning", "MalformedResource", "MalformedResource",
-			"Resource spec does not match expected schema: %v", err)
-		return ctrl.Result{}, fmt.Errorf("failed to convert unstructured to typed object: %w", err)
+	_, convertSpan := r.startSpan(ctx, spanConvert, raw)
+	err := fromUnstructured(raw.Object, obj)
+	migrated := false
+	if err != nil && r.MigrateUnstructuredFunc != nil {
+		if migratedRaw, migrateErr := r.migrate(log, raw, obj, err); migrateErr != nil {
+			err = migrateErr
+		} else {
+			raw, err, migrated = migratedRaw, nil, true
+		}
+	}
+	endSpan(convertSpan, err)
+	if err != nil {
+		if !raw.GetDeletionTimestamp().IsZero() {
+			return r.deleteMalformed(ctx, req, log, raw)
+		}
+
+		// the resource may be reconciled with another version of T by the reconciler whose scope it is in
+		if !r.inScope(raw) {
+			log.WithError(err).Info(3, "skipping malformed out of scope resource")
+			return ctrl.Result{}, nil
+		}
+
+		// malformed resources are not requeued, they are reconciled again once their generation changes
+		if r.isMarkedMalformed(raw) {
+			log.WithError(err).Info(3, "skipping malformed resource")
+			return ctrl.Result{}, nil
+		}
+
+		log.WithError(err).Error("malformed resource")
+		recordMalformedResource(r.gvk)
+		r.Events.Eventf(raw, nil, "Warning", ReasonMalformedResource, ReasonMalformedResource, "%s", malformedResourceMessage(err))
+		if err := r.markMalformed(ctx, raw, err); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to set malformed condition on %s: %w", resourceName, err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if migrated && r.WriteBackMigrations {
+		if err := r.writeBackMigration(ctx, log, raw, obj); err != nil {
+			log.WithError(err).Error("failed to write back migrated resource")
+			return r.requeue(req, obj, err, 2*time.Minute)
+		}
 	}
 
 	original := obj.DeepCopyObject()
 
+	if !r.inScope(obj) {
+		return r.releaseOutOfScope(ctx, req, log, obj, original)
+	}
+
 	if !obj.GetDeletionTimestamp().IsZero() {
-		klog.V(2).Infof("[kopper] deleting %s", resourceName)
-		if err := r.OnDeleteFunc(r.DutyContext, string(obj.GetUID())); err != nil {
-			klog.Errorf("[kopper] failed to delete %s: %v", resourceName, err)
-			if r.setCondition(obj, metav1.ConditionFalse, ReasonDeleteFailed, err.Error()) || r.syncObservedGeneration(obj) {
-				if statusErr := r.updateStatus(ctx, resourceName, obj, original); statusErr != nil {
-					err = errors.Join(err, fmt.Errorf("failed to update status for %s: %w", resourceName, statusErr))
-				}
-			}
-			return ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute}, err
+		if r.isPermanentlyFailed(obj, ReasonPermanentDeleteFailure) {
+			log.Info(2, "skipping delete of permanently failed resource")
+			return ctrl.Result{}, nil
+		}
+
+		log.Info(2, "deleting resource")
+		deleteCtx, deleteSpan := r.startSpan(ctx, spanDelete, obj)
+		started := time.Now()
+		err = r.runCallback(deleteCtx, obj, func(ctx context.Context) error {
+			return r.delete(ctx, obj)
+		})
+		recordCallback(r.gvk, metricsActionDelete, started, err)
+		endSpan(deleteSpan, err)
+		if isIgnoredError(err) {
+			log.WithAction(metricsActionDelete, started).WithError(err).Info(2, "ignoring delete error")
+		} else if err != nil {
+			log.WithAction(metricsActionDelete, started).WithError(err).Error("failed to delete resource")
+			return r.fail(ctx, req, log, obj, original, ReasonDeleteFailed, err, 2*time.Minute)
 		}
+		r.attempts.reset(req.NamespacedName)
 		controllerutil.RemoveFinalizer(obj, r.Finalizer)
 		r.Events.Eventf(obj, nil, "Normal", "Deleted", "Deleted", "Deleted %s", resourceName)
-		return ctrl.Result{}, r.Update(ctx, obj)
+		return ctrl.Result{}, r.updateFinalizers(ctx, obj)
 	}
 
-	isCreated := false
-	if !controllerutil.ContainsFinalizer(obj, r.Finalizer) {
-		controllerutil.AddFinalizer(obj, r.Finalizer)
-		if err := r.Update(ctx, obj); err != nil {
-			klog.Errorf("[kopper] failed to update finalizers %s: %v", resourceName, err)
-			return ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute}, err
+	isCreated := !controllerutil.ContainsFinalizer(obj, r.Finalizer)
+	if !r.isOwned(obj) {
+		r.setOwned(obj)
+		if err := r.updateFinalizers(ctx, obj); err != nil {
+			log.WithError(err).Error("failed to update finalizers")
+			return r.requeue(req, obj, err, 2*time.Minute)
 		}
-		isCreated = true
 	}
 
-	isUpdated := r.isObservedGenerationOutdated(obj)
+	if r.isPermanentlyFailed(obj, ReasonPermanentFailure) {
+		log.Info(2, "skipping upsert of permanently failed resource")
+		return ctrl.Result{}, nil
+	}
 
-	if err := r.OnUpsertFunc(r.DutyContext, obj); err != nil {
-		if isUniqueConstraintError(err) && r.OnConflictFunc != nil {
-			klog.V(2).Infof("[kopper] deleting %s due to unique constraint violation", resourceName)
+	if r.isPaused(obj) {
+		if err := r.pause(ctx, log, obj, original); err != nil {
+			return r.requeue(req, obj, err, 2*time.Minute)
+		}
+		return ctrl.Result{}, nil
+	}
+	r.resume(log, obj)
+
+	var hash string
+	if r.SkipUnchangedSpec {
+		if hash, err = specHash(raw); err != nil {
+			log.WithError(err).Error("failed to hash spec")
+		}
+	}
 
-			if err := r.OnConflictFunc(r.DutyContext, obj); err != nil {
-				klog.Errorf("[kopper] failed to delete %s: %v", resourceName, err)
-				return ctrl.Result{Requeue: true, RequeueAfter: time.Minute * 5}, err
+	isUpdated := r.isObservedGenerationOutdated(obj)
+	upserted := false
+	started := time.Now()
+
+	if r.isSpecUnchanged(obj, hash, r.forcedResyncs.has(req.NamespacedName)) {
+		log.Info(3, "skipping upsert of unchanged resource")
+	} else if err := r.upsert(ctx, obj); isIgnoredError(err) {
+		log.WithAction(metricsActionUpsert, started).WithError(err).Info(2, "ignoring upsert error")
+		upserted = true
+	} else if err != nil {
+		if isUniqueConstraintError(err) && r.OnConflictFunc != nil {
+			log.WithAction(metricsActionUpsert, started).WithError(err).Info(2, "deleting conflicting resource")
+
+			conflictCtx, conflictSpan := r.startSpan(ctx, spanConflict, obj)
+			started := time.Now()
+			conflictErr := r.runCallback(conflictCtx, obj, func(ctx context.Context) error {
+				return r.OnConflictFunc(ctx, obj)
+			})
+			recordReconcile(r.gvk, metricsActionConflict, conflictErr)
+			endSpan(conflictSpan, conflictErr)
+			if conflictErr != nil {
+				log.WithAction(metricsActionConflict, started).WithError(conflictErr).Error("failed to delete resource")
+				return r.requeue(req, obj, &ConflictError{Err: conflictErr}, time.Minute*5)
 			}
 
 			// after successful deletion, retry after a short delay
-			return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 15}, err
+			return r.requeue(req, obj, &ConflictError{Err: err, Resolved: true}, time.Second*15)
 		}
 
-		klog.Errorf("[kopper] failed to upsert %s: %v", resourceName, err)
-		if r.setCondition(obj, metav1.ConditionFalse, ReasonPersistFailed, err.Error()) || r.syncObservedGeneration(obj) {
-			if statusErr := r.updateStatus(ctx, resourceName, obj, original); statusErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to update status for %s: %w", resourceName, statusErr))
-			}
-		}
-		return ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute}, err
+		log.WithAction(metricsActionUpsert, started).WithError(err).Error("failed to upsert")
+		return r.fail(ctx, req, log, obj, original, ReasonPersistFailed, err, 2*time.Minute)
+	} else {
+		upserted = true
 	}
 
+	if upserted {
+		r.recordSpecHash(obj, hash)
+		// a skipped upsert leaves a drifted database record as it is
+		r.clearDrifted(obj)
+	}
 	r.setCondition(obj, metav1.ConditionTrue, ReasonSynced, "")
 	r.syncObservedGeneration(obj)
-	if err := r.updateStatus(ctx, resourceName, obj, original); err != nil {
-		return ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute}, err
+	if err := r.updateStatus(ctx, log, obj, original); err != nil {
+		return r.requeue(req, obj, err, 2*time.Minute)
+	}
+	if upserted {
+		if err := r.patchSpecHashAnnotations(ctx, obj, hash); err != nil {
+			log.WithError(err).Error("failed to record spec hash")
+			return r.requeue(req, obj, err, 2*time.Minute)
+		}
 	}
+	r.attempts.reset(req.NamespacedName)
 
 	if isCreated || isUpdated {
 		action := lo.Ternary(isCreated, "Created", "Updated")
-		klog.V(2).Infof("[kopper] %s %s", action, resourceName)
+		log.WithAction(metricsActionUpsert, started).Info(2, action)
 		r.Events.Eventf(obj, nil, "Normal", action, action, "%s %s", action, resourceName)
 	}
 	return ctrl.Result{}, nil
 }
 
+func (r *Reconciler[T, PT]) upsert(ctx gocontext.Context, obj PT) error {
+	upsertCtx, span := r.startSpan(ctx, spanUpsert, obj)
+	started := time.Now()
+	err := r.runCallback(upsertCtx, obj, func(ctx context.Context) error {
+		if err := r.applyDefaults(ctx, obj); err != nil {
+			return err
+		}
+		return r.OnUpsertFunc(ctx, obj)
+	})
+	recordCallback(r.gvk, metricsActionUpsert, started, err)
+	endSpan(span, err)
+	return err
+}
+
+func (r *Reconciler[T, PT]) delete(ctx context.Context, obj PT) error {
+	if r.OnDeleteObjectFunc != nil {
+		return r.OnDeleteObjectFunc(ctx, obj)
+	}
+	return r.OnDeleteFunc(ctx, string(obj.GetUID()))
+}
+
+// updateFinalizers writes the finalizers of obj, and the ScopeAnnotation of scoped reconcilers,
+// with a metadata-only merge patch, so a spec migrated in memory is not written back
+// unless WriteBackMigrations is set.
+func (r *Reconciler[T, PT]) updateFinalizers(ctx gocontext.Context, obj client.Object) (err error) {
+	ctx, span := r.startSpan(ctx, spanUpdateFinalizer, obj)
+	defer func() { endSpan(span, err) }()
+
+	metadata := map[string]any{
+		"finalizers":      obj.GetFinalizers(),
+		"resourceVersion": obj.GetResourceVersion(),
+	}
+	if r.isScoped() {
+		// a null value removes the annotation
+		var scope any
+		if id, ok := obj.GetAnnotations()[ScopeAnnotation]; ok {
+			scope = id
+		}
+		metadata["annotations"] = map[string]any{ScopeAnnotation: scope}
+	}
+
+	patch, err := json.Marshal(map[string]any{"metadata": metadata})
+	if err != nil {
+		return err
+	}
+
+	// the stored resource may not convert to PT, so the response is read as unstructured
+	patched := r.unstructuredRef(obj)
+	if err := r.Patch(ctx, patched, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return err
+	}
+
+	obj.SetResourceVersion(patched.GetResourceVersion())
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 //
+// Updates that only change the status or unrelated metadata are filtered out by
+// DefaultPredicate, so status patches written here do not trigger another reconcile.
+//
 // Resources are watched as Unstructured to ensure cache synchronization succeeds
 // even when some resources have specs that don't match the Go type definitions.
 // Malformed resources are detected during the Unstructured-to-typed conversion
-// in Reconcile(), where they can be handled gracefully with a warning event
-// rather than crashing the controller.
+// in Reconcile(), where they are reported with a warning event and a Ready=False
+// condition rather than crashing the controller.
 func (r *Reconciler[T, PT]) SetupWithManager(mgr ctrl.Manager) error {
 	pObj := PT(new(T))
 
@@ -274,13 +681,78 @@ func (r *Reconciler[T, PT]) SetupWithManager(mgr ctrl.Manager) error {
 		return fmt.Errorf("failed to get GVK for object: %w", err)
 	}
 	r.gvk = gvk
+	r.cache = mgr.GetCache()
+	r.apiReader = mgr.GetAPIReader()
+
+	if r.attempts == nil {
+		r.attempts = newAttemptTracker()
+	}
+	if r.forcedResyncs == nil {
+		r.forcedResyncs = newKeySet()
+	}
 
 	raw := &unstructured.Unstructured{}
 	raw.SetGroupVersionKind(gvk)
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(raw).
-		Complete(r)
+	if err := r.addOrphanSweeper(mgr); err != nil {
+		return err
+	}
+
+	r.resyncEvents = make(chan event.GenericEvent)
+	r.elected = mgr.Elected()
+	if err := r.addStartupResync(mgr); err != nil {
+		return err
+	}
+
+	if err := r.addDriftCheck(mgr); err != nil {
+		return err
+	}
+
+	r.addWebhooks(mgr)
+	if err := r.addConversionWebhook(mgr); err != nil {
+		return err
+	}
+
+	eventFilter := DefaultPredicate()
+	if r.LabelSelector != nil {
+		// label changes can move resources in or out of scope
+		eventFilter = predicate.Or(eventFilter, predicate.LabelChangedPredicate{})
+	}
+	predicates := append([]predicate.Predicate{eventFilter}, r.Predicates...)
+
+	blder := ctrl.NewControllerManagedBy(mgr).
+		For(raw, builder.WithPredicates(predicates...)).
+		WatchesRawSource(source.Channel(r.resyncEvents, &handler.EnqueueRequestForObject{})).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+			RateLimiter:             r.RateLimiter,
+			ReconciliationTimeout:   r.ReconcileTimeout,
+		})
+	if r.ControllerName != "" {
+		blder = blder.Named(r.ControllerName)
+	}
+
+	return blder.Complete(r)
+}
+
+// listResources lists every resource of the reconciled kind as unstructured.
+func (r *Reconciler[T, PT]) listResources(ctx gocontext.Context) (*unstructured.UnstructuredList, error) {
+	reader := r.cache
+	if reader == nil {
+		reader = r.Client
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(r.gvk.GroupVersion().WithKind(r.gvk.Kind + "List"))
+	if err := reader.List(ctx, list); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.gvk.Kind, err)
+	}
+	return list, nil
+}
+
+// malformedResourceMessage describes a failed conversion to the typed object.
+func malformedResourceMessage(err error) string {
+	return fmt.Sprintf("Resource spec does not match expected schema: %v", err)
 }
 
 // fromUnstructured converts an unstructured object to a typed object,
@@ -0,0 +1,326 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testGVK = schema.GroupVersionKind{Group: "test.kopper.io", Version: "v1", Kind: "TestResource"}
+
+type testResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   testResourceSpec   `json:"spec,omitempty"`
+	Status testResourceStatus `json:"status,omitempty"`
+}
+
+type testResourceSpec struct {
+	Message string `json:"message,omitempty"`
+}
+
+type testResourceStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+func (in *testResource) DeepCopyObject() runtime.Object {
+	out := &testResource{TypeMeta: in.TypeMeta, Spec: in.Spec, Status: in.Status}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Status.Conditions != nil {
+		out.Status.Conditions = make([]metav1.Condition, len(in.Status.Conditions))
+		for i := range in.Status.Conditions {
+			in.Status.Conditions[i].DeepCopyInto(&out.Status.Conditions[i])
+		}
+	}
+	return out
+}
+
+func (in *testResource) GetStatusConditions() *[]metav1.Condition {
+	return &in.Status.Conditions
+}
+
+func (in *testResource) SetObservedGeneration(generation int64) {
+	in.Status.ObservedGeneration = generation
+}
+
+func (in *testResource) GetObservedGeneration() int64 {
+	return in.Status.ObservedGeneration
+}
+
+type testResourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []testResource `json:"items"`
+}
+
+func (in *testResourceList) DeepCopyObject() runtime.Object {
+	out := &testResourceList{TypeMeta: in.TypeMeta}
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	for i := range in.Items {
+		out.Items = append(out.Items, *in.Items[i].DeepCopyObject().(*testResource))
+	}
+	return out
+}
+
+type testReconciler = Reconciler[testResource, *testResource]
+
+func newTestResource(name string) *testResource {
+	return &testResource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  "default",
+			UID:        types.UID(name + "-uid"),
+			Generation: 1,
+			Finalizers: []string{"test.kopper.io"},
+		},
+		Spec: testResourceSpec{Message: "hello"},
+	}
+}
+
+func newTestReconciler(t *testing.T, objs ...client.Object) (*testReconciler, *events.FakeRecorder) {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(testGVK, &testResource{})
+	s.AddKnownTypeWithName(testGVK.GroupVersion().WithKind("TestResourceList"), &testResourceList{})
+	metav1.AddToGroupVersion(s, testGVK.GroupVersion())
+
+	recorder := events.NewFakeRecorder(10)
+	r := &testReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(s).
+			WithObjects(objs...).
+			WithStatusSubresource(&testResource{}).
+			Build(),
+		DutyContext:   context.New(),
+		Scheme:        s,
+		Finalizer:     "test.kopper.io",
+		Events:        recorder,
+		OnUpsertFunc:  func(context.Context, *testResource) error { return nil },
+		OnDeleteFunc:  func(context.Context, string) error { return nil },
+		gvk:           testGVK,
+		attempts:      newAttemptTracker(),
+		forcedResyncs: newKeySet(),
+	}
+	return r, recorder
+}
+
+func reconcileTestResource(t *testing.T, r *testReconciler, name string) (ctrl.Result, *testResource, error) {
+	t.Helper()
+
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	result, err := r.Reconcile(gocontext.Background(), ctrl.Request{NamespacedName: key})
+
+	obj := &testResource{}
+	if getErr := r.Get(gocontext.Background(), key, obj); getErr != nil {
+		t.Fatalf("failed to get %s: %v", name, getErr)
+	}
+	return result, obj, err
+}
+
+func TestReconcileSynced(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("synced"))
+
+	upserts := 0
+	r.OnUpsertFunc = func(context.Context, *testResource) error {
+		upserts++
+		return nil
+	}
+
+	result, obj, err := reconcileTestResource(t, r, "synced")
+	if err != nil || !result.IsZero() {
+		t.Fatalf("expected successful reconcile, got %+v, %v", result, err)
+	}
+	if upserts != 1 {
+		t.Errorf("expected 1 upsert, got %d", upserts)
+	}
+
+	ready := k8smeta.FindStatusCondition(obj.Status.Conditions, ReadyConditionType)
+	if ready == nil || ready.Status != metav1.ConditionTrue || ready.Reason != ReasonSynced {
+		t.Errorf("expected Ready=True/%s, got %+v", ReasonSynced, ready)
+	}
+	if obj.Status.ObservedGeneration != 1 {
+		t.Errorf("expected observedGeneration 1, got %d", obj.Status.ObservedGeneration)
+	}
+}
+
+func TestReconcileErrorClassification(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedResult ctrl.Result
+		expectErr      bool
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "transient error",
+			err:            errors.New("connection refused"),
+			expectedResult: ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute},
+			expectErr:      true,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ReasonPersistFailed,
+		},
+		{
+			name:           "permanent error",
+			err:            Permanent(errors.New("invalid spec")),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ReasonPermanentFailure,
+		},
+		{
+			name:           "retry after error",
+			err:            RetryAfter(errors.New("rate limited"), 10*time.Second),
+			expectedResult: ctrl.Result{RequeueAfter: 10 * time.Second},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ReasonPersistFailed,
+		},
+		{
+			name:           "retry after error without a delay",
+			err:            RetryAfter(errors.New("rate limited"), 0),
+			expectedResult: ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute},
+			expectErr:      true,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ReasonPersistFailed,
+		},
+		{
+			name:           "ignored error",
+			err:            Ignore(errors.New("already exists")),
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: ReasonSynced,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestReconciler(t, newTestResource("resource"))
+			r.OnUpsertFunc = func(context.Context, *testResource) error { return tt.err }
+
+			result, obj, err := reconcileTestResource(t, r, "resource")
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error: %v, got %v", tt.expectErr, err)
+			}
+			if result != tt.expectedResult {
+				t.Errorf("expected result %+v, got %+v", tt.expectedResult, result)
+			}
+
+			ready := k8smeta.FindStatusCondition(obj.Status.Conditions, ReadyConditionType)
+			if ready == nil || ready.Status != tt.expectedStatus || ready.Reason != tt.expectedReason {
+				t.Errorf("expected Ready=%s/%s, got %+v", tt.expectedStatus, tt.expectedReason, ready)
+			}
+		})
+	}
+}
+
+func TestReconcilePermanentErrorStopsUntilGenerationChanges(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("permanent"))
+
+	upserts := 0
+	r.OnUpsertFunc = func(context.Context, *testResource) error {
+		upserts++
+		return Permanent(errors.New("invalid spec"))
+	}
+
+	for range 3 {
+		if _, _, err := reconcileTestResource(t, r, "permanent"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if upserts != 1 {
+		t.Fatalf("expected permanent failure to be attempted once, got %d", upserts)
+	}
+
+	_, obj, _ := reconcileTestResource(t, r, "permanent")
+	obj.Generation = 2
+	if err := r.Update(gocontext.Background(), obj); err != nil {
+		t.Fatalf("failed to bump generation: %v", err)
+	}
+
+	if _, _, err := reconcileTestResource(t, r, "permanent"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if upserts != 2 {
+		t.Errorf("expected upsert to be retried after generation change, got %d", upserts)
+	}
+}
+
+func TestReconcileDeleteAfterPermanentUpsertFailure(t *testing.T) {
+	obj := newTestResource("permanent")
+	obj.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	obj.Status.Conditions = []metav1.Condition{{
+		Type:               ReadyConditionType,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonPermanentFailure,
+		ObservedGeneration: obj.Generation,
+		LastTransitionTime: metav1.Now(),
+	}}
+
+	r, _ := newTestReconciler(t, obj)
+
+	deletes := 0
+	r.OnDeleteFunc = func(context.Context, string) error {
+		deletes++
+		return Permanent(errors.New("still referenced"))
+	}
+
+	for range 2 {
+		if _, _, err := reconcileTestResource(t, r, "permanent"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if deletes != 1 {
+		t.Errorf("expected the delete to be attempted once despite the upsert failure, got %d", deletes)
+	}
+
+	_, obj, _ = reconcileTestResource(t, r, "permanent")
+	if ready := k8smeta.FindStatusCondition(obj.Status.Conditions, ReadyConditionType); ready == nil || ready.Reason != ReasonPermanentDeleteFailure {
+		t.Errorf("expected Ready=False/%s, got %+v", ReasonPermanentDeleteFailure, ready)
+	}
+}
+
+func TestReconcileDeleteObject(t *testing.T) {
+	obj := newTestResource("deleted")
+	obj.Labels = map[string]string{"app": "kopper"}
+	obj.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	r, _ := newTestReconciler(t, obj)
+
+	var deleted *testResource
+	r.OnDeleteObjectFunc = func(_ context.Context, obj *testResource) error {
+		deleted = obj
+		return nil
+	}
+	r.OnDeleteFunc = func(context.Context, string) error {
+		t.Error("expected OnDeleteObjectFunc to take precedence over OnDeleteFunc")
+		return nil
+	}
+
+	key := types.NamespacedName{Namespace: "default", Name: "deleted"}
+	if _, err := r.Reconcile(gocontext.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if deleted == nil {
+		t.Fatal("expected OnDeleteObjectFunc to be called")
+	}
+	if deleted.Name != "deleted" || deleted.Labels["app"] != "kopper" || deleted.Spec.Message != "hello" {
+		t.Errorf("expected the deleted object to be passed, got %+v", deleted)
+	}
+
+	if err := r.Get(gocontext.Background(), key, &testResource{}); err == nil {
+		t.Error("expected finalizer to be removed and the object deleted")
+	}
+}
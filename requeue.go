@@ -0,0 +1,163 @@
+package kopper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// RequeuePolicy decides when a resource is reconciled again after a failed attempt.
+//
+// attempt is the number of consecutive failures for the resource, starting at 1.
+// Returning a zero ctrl.Result hands the error back to controller-runtime,
+// which requeues with its own rate limiter.
+type RequeuePolicy interface {
+	Requeue(err error, attempt int, obj client.Object) ctrl.Result
+}
+
+// RequeuePolicyFunc adapts a function to a RequeuePolicy.
+type RequeuePolicyFunc func(err error, attempt int, obj client.Object) ctrl.Result
+
+func (f RequeuePolicyFunc) Requeue(err error, attempt int, obj client.Object) ctrl.Result {
+	return f(err, attempt, obj)
+}
+
+// FixedRequeuePolicy requeues after the same interval regardless of the attempt.
+type FixedRequeuePolicy struct {
+	Interval time.Duration
+}
+
+func (p FixedRequeuePolicy) Requeue(_ error, _ int, _ client.Object) ctrl.Result {
+	return ctrl.Result{RequeueAfter: p.Interval}
+}
+
+// ExponentialRequeuePolicy doubles the delay on every attempt, starting at BaseDelay
+// and capped at MaxDelay. Jitter adds up to Jitter*delay of random delay so that
+// resources failing together do not retry together.
+type ExponentialRequeuePolicy struct {
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Jitter    float64
+}
+
+func (p ExponentialRequeuePolicy) Requeue(_ error, attempt int, _ client.Object) ctrl.Result {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	requeueAfter := time.Duration(delay)
+	if p.Jitter > 0 {
+		requeueAfter = wait.Jitter(requeueAfter, p.Jitter)
+	}
+	if p.MaxDelay > 0 && requeueAfter > p.MaxDelay {
+		requeueAfter = p.MaxDelay
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}
+}
+
+// ErrorClass routes errors matched by Match to Policy.
+type ErrorClass struct {
+	Match  func(error) bool
+	Policy RequeuePolicy
+}
+
+// ErrorClassRequeuePolicy picks the policy of the first ErrorClass that matches the error,
+// falling back to Default. Classes without a Policy are skipped.
+type ErrorClassRequeuePolicy struct {
+	Classes []ErrorClass
+	Default RequeuePolicy
+}
+
+func (p ErrorClassRequeuePolicy) Requeue(err error, attempt int, obj client.Object) ctrl.Result {
+	for _, class := range p.Classes {
+		if class.Policy != nil && class.Match != nil && class.Match(err) {
+			return class.Policy.Requeue(err, attempt, obj)
+		}
+	}
+
+	if p.Default == nil {
+		return ctrl.Result{}
+	}
+	return p.Default.Requeue(err, attempt, obj)
+}
+
+// ConflictError is handed to the RequeuePolicy when OnUpsertFunc failed with a
+// unique constraint violation and OnConflictFunc was invoked.
+// Resolved reports whether OnConflictFunc succeeded.
+type ConflictError struct {
+	Err      error
+	Resolved bool
+}
+
+func (e *ConflictError) Error() string {
+	if e.Resolved {
+		return fmt.Sprintf("resolved conflict: %v", e.Err)
+	}
+	return fmt.Sprintf("failed to resolve conflict: %v", e.Err)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// IsConflictError matches errors produced while handling unique constraint violations.
+func IsConflictError(err error) bool {
+	var conflictErr *ConflictError
+	return errors.As(err, &conflictErr)
+}
+
+// attemptTracker counts consecutive failed reconciles per resource.
+type attemptTracker struct {
+	mu       sync.Mutex
+	attempts map[types.NamespacedName]int
+}
+
+func newAttemptTracker() *attemptTracker {
+	return &attemptTracker{attempts: make(map[types.NamespacedName]int)}
+}
+
+func (t *attemptTracker) failed(key types.NamespacedName) int {
+	if t == nil {
+		return 1
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempts[key]++
+	return t.attempts[key]
+}
+
+func (t *attemptTracker) reset(key types.NamespacedName) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.attempts, key)
+}
+
+// NewRateLimiter returns a workqueue rate limiter for WithRateLimiter, combining a per-item
+// exponential backoff from baseDelay to maxDelay with an overall token bucket of qps and burst.
+func NewRateLimiter(baseDelay, maxDelay time.Duration, qps float64, burst int) workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](baseDelay, maxDelay),
+		&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
+}
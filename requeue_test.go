@@ -0,0 +1,151 @@
+package kopper
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestExponentialRequeuePolicy(t *testing.T) {
+	policy := ExponentialRequeuePolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		result := policy.Requeue(errors.New("failed"), tt.attempt, &corev1.ConfigMap{})
+		if result.RequeueAfter != tt.expected {
+			t.Errorf("attempt %d: RequeueAfter = %v, want %v", tt.attempt, result.RequeueAfter, tt.expected)
+		}
+	}
+}
+
+func TestExponentialRequeuePolicyJitter(t *testing.T) {
+	policy := ExponentialRequeuePolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.5}
+
+	for range 100 {
+		result := policy.Requeue(errors.New("failed"), 3, &corev1.ConfigMap{})
+		if result.RequeueAfter < 4*time.Second || result.RequeueAfter > 6*time.Second {
+			t.Fatalf("RequeueAfter = %v, want between 4s and 6s", result.RequeueAfter)
+		}
+	}
+}
+
+func TestErrorClassRequeuePolicy(t *testing.T) {
+	errValidation := errors.New("validation failed")
+
+	policy := ErrorClassRequeuePolicy{
+		Classes: []ErrorClass{
+			{Match: func(err error) bool { return errors.Is(err, errValidation) }, Policy: FixedRequeuePolicy{Interval: time.Hour}},
+			{Match: IsConflictError, Policy: FixedRequeuePolicy{Interval: 15 * time.Second}},
+		},
+		Default: FixedRequeuePolicy{Interval: 5 * time.Second},
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected time.Duration
+	}{
+		{"validation error", errValidation, time.Hour},
+		{"wrapped validation error", errors.Join(errors.New("upsert"), errValidation), time.Hour},
+		{"conflict error", &ConflictError{Err: errors.New("duplicate key"), Resolved: true}, 15 * time.Second},
+		{"default", errors.New("connection refused"), 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := policy.Requeue(tt.err, 1, &corev1.ConfigMap{})
+			if result.RequeueAfter != tt.expected {
+				t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, tt.expected)
+			}
+		})
+	}
+
+	if result := (ErrorClassRequeuePolicy{}).Requeue(errors.New("failed"), 1, &corev1.ConfigMap{}); !result.IsZero() {
+		t.Errorf("expected zero result without a default policy, got %+v", result)
+	}
+
+	withoutPolicy := ErrorClassRequeuePolicy{
+		Classes: []ErrorClass{{Match: func(error) bool { return true }}},
+		Default: FixedRequeuePolicy{Interval: 5 * time.Second},
+	}
+	if result := withoutPolicy.Requeue(errors.New("failed"), 1, &corev1.ConfigMap{}); result.RequeueAfter != 5*time.Second {
+		t.Errorf("expected a class without a policy to fall through to the default, got %+v", result)
+	}
+}
+
+func TestReconcilerRequeue(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	req := ctrl.Request{NamespacedName: key}
+	errFailed := errors.New("failed")
+
+	var attempts []int
+	r := &Reconciler[corev1.ConfigMap, *corev1.ConfigMap]{
+		attempts: newAttemptTracker(),
+		RequeuePolicy: RequeuePolicyFunc(func(err error, attempt int, obj client.Object) ctrl.Result {
+			attempts = append(attempts, attempt)
+			return ctrl.Result{RequeueAfter: time.Duration(attempt) * time.Second}
+		}),
+	}
+
+	for range 3 {
+		result, err := r.requeue(req, &corev1.ConfigMap{}, errFailed, time.Minute)
+		if err != nil {
+			t.Fatalf("expected error to be handled by the policy, got %v", err)
+		}
+		if result.RequeueAfter != time.Duration(len(attempts))*time.Second {
+			t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, time.Duration(len(attempts))*time.Second)
+		}
+	}
+
+	r.attempts.reset(key)
+	_, _ = r.requeue(req, &corev1.ConfigMap{}, errFailed, time.Minute)
+
+	if expected := []int{1, 2, 3, 1}; !slices.Equal(attempts, expected) {
+		t.Errorf("attempts = %v, want %v", attempts, expected)
+	}
+
+	r.RequeuePolicy = nil
+	result, err := r.requeue(req, &corev1.ConfigMap{}, errFailed, time.Minute)
+	if !errors.Is(err, errFailed) || result.RequeueAfter != time.Minute {
+		t.Errorf("expected fallback result with error, got %+v, %v", result, err)
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(10*time.Millisecond, 100*time.Millisecond, 1000, 100)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "limited"}}
+
+	var delays []time.Duration
+	for range 6 {
+		delays = append(delays, limiter.When(req))
+	}
+	expected := []time.Duration{10, 20, 40, 80, 100, 100}
+	for i := range expected {
+		if delays[i] != expected[i]*time.Millisecond {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, expected[i]*time.Millisecond, delays[i])
+		}
+	}
+
+	limiter.Forget(req)
+	if delay := limiter.When(req); delay != 10*time.Millisecond {
+		t.Errorf("expected the backoff to reset after Forget, got %v", delay)
+	}
+}
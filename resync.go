@@ -0,0 +1,78 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ErrNotLeader is returned by Resync on replicas that do not run the controllers,
+// i.e. before the manager is started or when another replica holds the leader election lease.
+var ErrNotLeader = errors.New("not the leader")
+
+// Resync enqueues every resource of the reconciled kind, e.g. to re-upsert
+// all resources after a database restore. OnUpsertFunc runs even if the spec is unchanged.
+//
+// Only the leader runs the controllers, other replicas get ErrNotLeader.
+// It blocks until all resources are enqueued or ctx is done.
+func (r *Reconciler[T, PT]) Resync(ctx gocontext.Context) (int, error) {
+	if r.resyncEvents == nil {
+		return 0, fmt.Errorf("reconciler for %s is not set up with a manager", r.gvk.Kind)
+	}
+
+	select {
+	case <-r.elected:
+	default:
+		return 0, fmt.Errorf("failed to resync %s: %w", r.gvk.Kind, ErrNotLeader)
+	}
+
+	list, err := r.listResources(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range list.Items {
+		key := client.ObjectKeyFromObject(&list.Items[i])
+		r.forcedResyncs.add(key)
+		select {
+		case r.resyncEvents <- event.GenericEvent{Object: &list.Items[i]}:
+		case <-ctx.Done():
+			r.forcedResyncs.remove(key)
+			return i, ctx.Err()
+		}
+	}
+
+	r.kindLogger(ctx).With("count", len(list.Items)).Info(2, "enqueued resources for resync")
+	return len(list.Items), nil
+}
+
+// addStartupResync enqueues every resource once the cache has synced.
+// It only runs on the leader.
+func (r *Reconciler[T, PT]) addStartupResync(mgr ctrl.Manager) error {
+	if !r.ResyncOnStart {
+		return nil
+	}
+
+	return mgr.Add(manager.RunnableFunc(func(ctx gocontext.Context) error {
+		if !mgr.GetCache().WaitForCacheSync(ctx) {
+			return nil
+		}
+
+		// leader runnables start just before the election is reported as won
+		select {
+		case <-mgr.Elected():
+		case <-ctx.Done():
+			return nil
+		}
+
+		if _, err := r.Resync(ctx); err != nil {
+			r.kindLogger(ctx).WithError(err).Error("failed to resync")
+		}
+		return nil
+	}))
+}
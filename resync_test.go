@@ -0,0 +1,67 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestResync(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("one"), newTestResource("two"))
+
+	if _, err := r.Resync(gocontext.Background()); err == nil {
+		t.Fatal("expected an error before the reconciler is set up")
+	}
+
+	r.resyncEvents = make(chan event.GenericEvent, 10)
+	r.elected = closedChannel()
+	count, err := r.Resync(gocontext.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 resources to be enqueued, got %d", count)
+	}
+
+	close(r.resyncEvents)
+	var names []string
+	for e := range r.resyncEvents {
+		names = append(names, e.Object.GetName())
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"one", "two"}) {
+		t.Errorf("expected [one two] to be enqueued, got %v", names)
+	}
+}
+
+func closedChannel() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func TestResyncWithoutReader(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("one"))
+	r.resyncEvents = make(chan event.GenericEvent)
+
+	r.elected = make(chan struct{})
+	if _, err := r.Resync(gocontext.Background()); !errors.Is(err, ErrNotLeader) {
+		t.Fatalf("expected ErrNotLeader on a replica that is not the leader, got %v", err)
+	}
+
+	r.elected = closedChannel()
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 10*time.Millisecond)
+	defer cancel()
+	count, err := r.Resync(ctx)
+	if !errors.Is(err, gocontext.DeadlineExceeded) || count != 0 {
+		t.Fatalf("expected the resync to stop with its context, got %d, %v", count, err)
+	}
+	if r.forcedResyncs.has(types.NamespacedName{Namespace: "default", Name: "one"}) {
+		t.Error("expected resources that were not enqueued to be dropped from the forced resyncs")
+	}
+}
@@ -0,0 +1,144 @@
+package kopper
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// OutOfScopeAction decides how a resource that moves out of a reconciler's
+// namespaces or label selector is handled.
+type OutOfScopeAction string
+
+const (
+	// OutOfScopeOrphan removes the finalizer and leaves the persisted record in place.
+	OutOfScopeOrphan OutOfScopeAction = "Orphan"
+
+	// OutOfScopeDelete handles the resource as if it was deleted, then removes the finalizer.
+	OutOfScopeDelete OutOfScopeAction = "Delete"
+)
+
+const ReasonOutOfScope = "OutOfScope"
+
+// ScopeAnnotation identifies the scope of the reconciler that added the finalizer.
+// Scoped reconcilers only release out of scope resources that carry their own scope,
+// so reconcilers sharing a finalizer across disjoint scopes leave each other's resources alone.
+// Resources reconciled before a scope change keep the previous scope and are not released.
+// Likewise, resources that got the finalizer before the reconciler was scoped have no ScopeAnnotation:
+// they are adopted on their next reconcile in scope, but those already out of scope are never
+// released, even once deleted, so their finalizer has to be removed by hand.
+const ScopeAnnotation = "kopper.flanksource.com/scope"
+
+// isScoped reports whether the reconciler is limited by namespaces or a label selector.
+func (r *Reconciler[T, PT]) isScoped() bool {
+	return len(r.Namespaces) > 0 || r.LabelSelector != nil
+}
+
+// scopeID returns a stable identifier of the finalizer, namespaces and label selector of the reconciler.
+func (r *Reconciler[T, PT]) scopeID() string {
+	namespaces := slices.Sorted(slices.Values(r.Namespaces))
+	selector := ""
+	if r.LabelSelector != nil {
+		selector = r.LabelSelector.String()
+	}
+
+	data, _ := json.Marshal([]any{r.Finalizer, namespaces, selector})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// isOwned reports whether obj carries the finalizer and, for scoped reconcilers, the ScopeAnnotation of this reconciler.
+func (r *Reconciler[T, PT]) isOwned(obj client.Object) bool {
+	if !controllerutil.ContainsFinalizer(obj, r.Finalizer) {
+		return false
+	}
+	return !r.isScoped() || obj.GetAnnotations()[ScopeAnnotation] == r.scopeID()
+}
+
+// setOwned adds the finalizer and, for scoped reconcilers, the ScopeAnnotation to obj.
+func (r *Reconciler[T, PT]) setOwned(obj PT) {
+	controllerutil.AddFinalizer(obj, r.Finalizer)
+	if !r.isScoped() {
+		return
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ScopeAnnotation] = r.scopeID()
+	obj.SetAnnotations(annotations)
+}
+
+// inScope reports whether obj matches the reconciler's namespaces and label selector.
+func (r *Reconciler[T, PT]) inScope(obj client.Object) bool {
+	if len(r.Namespaces) > 0 && !slices.Contains(r.Namespaces, obj.GetNamespace()) {
+		return false
+	}
+	if r.LabelSelector != nil && !r.LabelSelector.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	return true
+}
+
+// releaseOutOfScope hands off a resource reconciled by this reconciler that no longer matches the scope,
+// including resources deleted after they moved out of scope.
+// Resources owned by another scope sharing the finalizer are left alone.
+func (r *Reconciler[T, PT]) releaseOutOfScope(ctx gocontext.Context, req ctrl.Request, log objectLogger, obj PT, original runtime.Object) (ctrl.Result, error) {
+	if !r.isOwned(obj) {
+		if controllerutil.ContainsFinalizer(obj, r.Finalizer) {
+			log.Info(3, "skipping out of scope resource owned by another scope")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	switch r.OutOfScopeAction {
+	case OutOfScopeDelete:
+		if r.isPermanentlyFailed(obj, ReasonPermanentDeleteFailure) {
+			log.Info(2, "skipping delete of permanently failed resource")
+			return ctrl.Result{}, nil
+		}
+
+		log.Info(2, "deleting out of scope resource")
+		deleteCtx, deleteSpan := r.startSpan(ctx, spanDelete, obj)
+		started := time.Now()
+		err := r.runCallback(deleteCtx, obj, func(ctx context.Context) error {
+			return r.delete(ctx, obj)
+		})
+		recordCallback(r.gvk, metricsActionDelete, started, err)
+		endSpan(deleteSpan, err)
+		if isIgnoredError(err) {
+			log.WithAction(metricsActionDelete, started).WithError(err).Info(2, "ignoring delete error")
+		} else if err != nil {
+			log.WithAction(metricsActionDelete, started).WithError(err).Error("failed to delete resource")
+			return r.fail(ctx, req, log, obj, original, ReasonDeleteFailed, err, 2*time.Minute)
+		}
+	case OutOfScopeOrphan, "":
+		log.Info(2, "orphaning out of scope resource")
+	default:
+		return ctrl.Result{}, fmt.Errorf("unknown out of scope action %q", r.OutOfScopeAction)
+	}
+
+	controllerutil.RemoveFinalizer(obj, r.Finalizer)
+	annotations := obj.GetAnnotations()
+	delete(annotations, ScopeAnnotation)
+	obj.SetAnnotations(annotations)
+	if err := r.updateFinalizers(ctx, obj); err != nil {
+		return r.requeue(req, obj, err, 2*time.Minute)
+	}
+
+	r.attempts.reset(req.NamespacedName)
+	r.Events.Eventf(obj, nil, "Normal", ReasonOutOfScope, ReasonOutOfScope, "Released %s: out of scope", log.resourceName)
+	return ctrl.Result{}, nil
+}
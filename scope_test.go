@@ -0,0 +1,288 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"github.com/samber/lo"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestReconcileOutOfScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  OutOfScopeAction
+		deletes int
+	}{
+		{name: "orphan", action: OutOfScopeOrphan, deletes: 0},
+		{name: "default", deletes: 0},
+		{name: "delete", action: OutOfScopeDelete, deletes: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := newTestResource("scoped")
+			obj.Labels = map[string]string{"team": "a"}
+
+			r, _ := newTestReconciler(t, obj)
+			r.LabelSelector = labels.SelectorFromSet(labels.Set{"team": "a"})
+			r.OutOfScopeAction = tt.action
+
+			upserts, deletes := 0, 0
+			r.OnUpsertFunc = func(context.Context, *testResource) error {
+				upserts++
+				return nil
+			}
+			r.OnDeleteFunc = func(context.Context, string) error {
+				deletes++
+				return nil
+			}
+
+			_, obj, err := reconcileTestResource(t, r, "scoped")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if obj.Annotations[ScopeAnnotation] != r.scopeID() {
+				t.Fatalf("expected the scope annotation to be set, got %v", obj.Annotations)
+			}
+
+			obj.Labels = map[string]string{"team": "b"}
+			if err := r.Update(gocontext.Background(), obj); err != nil {
+				t.Fatalf("failed to relabel: %v", err)
+			}
+
+			_, obj, err = reconcileTestResource(t, r, "scoped")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if upserts != 1 {
+				t.Errorf("expected OnUpsertFunc to be skipped out of scope, got %d calls", upserts)
+			}
+			if deletes != tt.deletes {
+				t.Errorf("expected %d OnDeleteFunc calls, got %d", tt.deletes, deletes)
+			}
+			if len(obj.Finalizers) != 0 {
+				t.Errorf("expected the finalizer to be removed, got %v", obj.Finalizers)
+			}
+			if _, ok := obj.Annotations[ScopeAnnotation]; ok {
+				t.Errorf("expected the scope annotation to be removed, got %v", obj.Annotations)
+			}
+		})
+	}
+}
+
+// testScopeID returns the scope of a test reconciler limited to selector.
+func testScopeID(selector labels.Selector) string {
+	r := &testReconciler{Finalizer: "test.kopper.io", LabelSelector: selector}
+	return r.scopeID()
+}
+
+func TestReconcileOutOfScopeDeleting(t *testing.T) {
+	tests := []struct {
+		name    string
+		owned   bool
+		deletes int
+	}{
+		{name: "owned by another scope", owned: false, deletes: 0},
+		{name: "owned", owned: true, deletes: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := newTestResource("scoped")
+			obj.Labels = map[string]string{"team": "b"}
+			obj.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+			selector := labels.SelectorFromSet(labels.Set{"team": "a"})
+			if tt.owned {
+				obj.Annotations = map[string]string{ScopeAnnotation: testScopeID(selector)}
+			}
+
+			r, _ := newTestReconciler(t, obj)
+			r.LabelSelector = selector
+			r.OutOfScopeAction = OutOfScopeDelete
+
+			deletes := 0
+			r.OnDeleteFunc = func(context.Context, string) error {
+				deletes++
+				return nil
+			}
+
+			key := types.NamespacedName{Namespace: "default", Name: "scoped"}
+			result, err := r.Reconcile(gocontext.Background(), ctrl.Request{NamespacedName: key})
+			if err != nil || !result.IsZero() {
+				t.Fatalf("unexpected result %+v, %v", result, err)
+			}
+			if deletes != tt.deletes {
+				t.Errorf("expected %d OnDeleteFunc calls, got %d", tt.deletes, deletes)
+			}
+
+			err = r.Get(gocontext.Background(), key, obj)
+			if tt.owned && !apiErrors.IsNotFound(err) {
+				t.Errorf("expected the finalizer to be removed, got %v", err)
+			}
+			if !tt.owned && (err != nil || !controllerutil.ContainsFinalizer(obj, r.Finalizer)) {
+				t.Errorf("expected the finalizer to be kept, got %v, %v", obj.Finalizers, err)
+			}
+		})
+	}
+}
+
+func TestReconcileOutOfScopeDeleteErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedResult ctrl.Result
+		expectErr      bool
+		expectedReason string
+	}{
+		{"transient error", errors.New("connection refused"), ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute}, true, ReasonDeleteFailed},
+		{"permanent error", Permanent(errors.New("still referenced")), ctrl.Result{}, false, ReasonPermanentDeleteFailure},
+		{"retry after error", RetryAfter(errors.New("rate limited"), 10*time.Second), ctrl.Result{RequeueAfter: 10 * time.Second}, false, ReasonDeleteFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := newTestResource("scoped")
+			obj.Labels = map[string]string{"team": "b"}
+
+			selector := labels.SelectorFromSet(labels.Set{"team": "a"})
+			obj.Annotations = map[string]string{ScopeAnnotation: testScopeID(selector)}
+
+			r, _ := newTestReconciler(t, obj)
+			r.LabelSelector = selector
+			r.OutOfScopeAction = OutOfScopeDelete
+
+			deletes := 0
+			r.OnDeleteFunc = func(context.Context, string) error {
+				deletes++
+				return tt.err
+			}
+
+			result, obj, err := reconcileTestResource(t, r, "scoped")
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error: %v, got %v", tt.expectErr, err)
+			}
+			if result != tt.expectedResult {
+				t.Errorf("expected result %+v, got %+v", tt.expectedResult, result)
+			}
+			if ready := k8smeta.FindStatusCondition(obj.Status.Conditions, ReadyConditionType); ready == nil || ready.Reason != tt.expectedReason {
+				t.Errorf("expected Ready reason %s, got %+v", tt.expectedReason, ready)
+			}
+			if !controllerutil.ContainsFinalizer(obj, r.Finalizer) {
+				t.Errorf("expected the finalizer to be kept, got %v", obj.Finalizers)
+			}
+
+			reconcileTestResource(t, r, "scoped")
+			if expected := lo.Ternary(tt.expectedReason == ReasonPermanentDeleteFailure, 1, 2); deletes != expected {
+				t.Errorf("expected %d OnDeleteFunc calls, got %d", expected, deletes)
+			}
+		})
+	}
+}
+
+func TestReconcileNamespaceScope(t *testing.T) {
+	obj := newTestResource("scoped")
+	obj.Finalizers = nil
+
+	r, recorder := newTestReconciler(t, obj)
+	r.Namespaces = []string{"other"}
+
+	upserts := 0
+	r.OnUpsertFunc = func(context.Context, *testResource) error {
+		upserts++
+		return nil
+	}
+
+	_, obj, err := reconcileTestResource(t, r, "scoped")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if upserts != 0 {
+		t.Errorf("expected OnUpsertFunc to be skipped, got %d calls", upserts)
+	}
+	if len(obj.Finalizers) != 0 {
+		t.Errorf("expected no finalizer on an unmanaged resource, got %v", obj.Finalizers)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no events for an unmanaged resource, got %d", len(recorder.Events))
+	}
+
+	r.Namespaces = append(r.Namespaces, "default")
+	if _, _, err := reconcileTestResource(t, r, "scoped"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if upserts != 1 {
+		t.Errorf("expected OnUpsertFunc to be called once in scope, got %d calls", upserts)
+	}
+}
+
+func TestReconcileOutOfScopeSharedFinalizer(t *testing.T) {
+	obj := newTestResource("shared")
+	obj.Finalizers = nil
+	obj.Labels = map[string]string{"team": "b"}
+
+	a, _ := newTestReconciler(t, obj)
+	a.LabelSelector = labels.SelectorFromSet(labels.Set{"team": "a"})
+	a.OutOfScopeAction = OutOfScopeDelete
+
+	b := &testReconciler{}
+	*b = *a
+	b.LabelSelector = labels.SelectorFromSet(labels.Set{"team": "b"})
+	b.attempts = newAttemptTracker()
+	b.forcedResyncs = newKeySet()
+
+	deletesA, deletesB := 0, 0
+	a.OnDeleteFunc = func(context.Context, string) error {
+		deletesA++
+		return nil
+	}
+	b.OnDeleteFunc = func(context.Context, string) error {
+		deletesB++
+		return nil
+	}
+
+	reconcile := func(r *testReconciler) *testResource {
+		t.Helper()
+		_, obj, err := reconcileTestResource(t, r, "shared")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return obj
+	}
+
+	reconcile(b)
+	obj = reconcile(a)
+	if deletesA != 0 {
+		t.Errorf("expected OnDeleteFunc of the other scope to be skipped, got %d calls", deletesA)
+	}
+	if !controllerutil.ContainsFinalizer(obj, b.Finalizer) || obj.Annotations[ScopeAnnotation] != b.scopeID() {
+		t.Fatalf("expected the resource to stay owned by the other scope, got %v %v", obj.Finalizers, obj.Annotations)
+	}
+
+	obj.Labels = map[string]string{"team": "a"}
+	if err := a.Update(gocontext.Background(), obj); err != nil {
+		t.Fatalf("failed to relabel: %v", err)
+	}
+	obj = reconcile(a)
+	if obj.Annotations[ScopeAnnotation] != a.scopeID() {
+		t.Fatalf("expected the resource to be taken over, got %v", obj.Annotations)
+	}
+
+	obj = reconcile(b)
+	if deletesB != 0 {
+		t.Errorf("expected OnDeleteFunc of the previous scope to be skipped, got %d calls", deletesB)
+	}
+	if !controllerutil.ContainsFinalizer(obj, a.Finalizer) || obj.Annotations[ScopeAnnotation] != a.scopeID() {
+		t.Errorf("expected the resource to stay owned by the new scope, got %v %v", obj.Finalizers, obj.Annotations)
+	}
+}
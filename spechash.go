@@ -0,0 +1,149 @@
+package kopper
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SpecHashAnnotation holds the hash of the last successfully upserted spec
+	// for CRDs that do not implement SpecHashRecorder.
+	SpecHashAnnotation = "kopper.flanksource.com/spec-hash"
+
+	// ForceResyncAnnotation forces OnUpsertFunc to run even if the spec is unchanged.
+	// It is removed after the next successful upsert.
+	ForceResyncAnnotation = "kopper.flanksource.com/force-resync"
+)
+
+// SpecHashRecorder allows a CRD to keep the hash of its last successfully
+// upserted spec in its status, instead of the SpecHashAnnotation.
+type SpecHashRecorder interface {
+	GetSpecHash() string
+	SetSpecHash(hash string)
+}
+
+// specHash returns a stable hash of the spec of raw.
+func specHash(raw *unstructured.Unstructured) (string, error) {
+	data, err := json.Marshal(raw.Object["spec"])
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal spec: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (r *Reconciler[T, PT]) storedSpecHash(obj PT) string {
+	if recorder, ok := any(obj).(SpecHashRecorder); ok {
+		return recorder.GetSpecHash()
+	}
+	return obj.GetAnnotations()[SpecHashAnnotation]
+}
+
+// isSpecUnchanged reports whether obj was already upserted with the given spec hash at its current generation.
+func (r *Reconciler[T, PT]) isSpecUnchanged(obj PT, hash string, forced bool) bool {
+	if !r.SkipUnchangedSpec || forced || hash == "" {
+		return false
+	}
+	if _, ok := obj.GetAnnotations()[ForceResyncAnnotation]; ok {
+		return false
+	}
+	return hash == r.storedSpecHash(obj) && !r.isObservedGenerationOutdated(obj)
+}
+
+// recordSpecHash stores the hash of a successfully upserted spec on SpecHashRecorders.
+// It is written with the status, so it must be called before updateStatus.
+func (r *Reconciler[T, PT]) recordSpecHash(obj PT, hash string) {
+	if hash == "" {
+		return
+	}
+	if recorder, ok := any(obj).(SpecHashRecorder); ok {
+		recorder.SetSpecHash(hash)
+	}
+}
+
+// patchSpecHashAnnotations writes the SpecHashAnnotation and removes the ForceResyncAnnotation, if needed.
+func (r *Reconciler[T, PT]) patchSpecHashAnnotations(ctx gocontext.Context, obj PT, hash string) error {
+	if hash == "" {
+		return nil
+	}
+
+	_, isRecorder := any(obj).(SpecHashRecorder)
+	annotations := obj.GetAnnotations()
+	_, forced := annotations[ForceResyncAnnotation]
+	if !forced && (isRecorder || annotations[SpecHashAnnotation] == hash) {
+		return nil
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	delete(annotations, ForceResyncAnnotation)
+	if !isRecorder {
+		annotations[SpecHashAnnotation] = hash
+	}
+	obj.SetAnnotations(annotations)
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	// the stored resource may not convert to PT, so the response is read as unstructured
+	patched := r.unstructuredRef(obj)
+	if err := r.Patch(ctx, patched, client.RawPatch(patch.Type(), data)); err != nil {
+		return err
+	}
+	obj.SetResourceVersion(patched.GetResourceVersion())
+	return nil
+}
+
+// keySet is a set of resources, e.g. those whose next reconcile must run OnUpsertFunc.
+type keySet struct {
+	mu   sync.Mutex
+	keys map[types.NamespacedName]struct{}
+}
+
+func newKeySet() *keySet {
+	return &keySet{keys: make(map[types.NamespacedName]struct{})}
+}
+
+func (s *keySet) add(key types.NamespacedName) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = struct{}{}
+}
+
+func (s *keySet) has(key types.NamespacedName) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok
+}
+
+func (s *keySet) remove(key types.NamespacedName) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+}
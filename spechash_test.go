@@ -0,0 +1,126 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileSkipUnchangedSpec(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("hashed"))
+	r.SkipUnchangedSpec = true
+
+	upserts := 0
+	r.OnUpsertFunc = func(context.Context, *testResource) error {
+		upserts++
+		return nil
+	}
+
+	reconcile := func() *testResource {
+		t.Helper()
+		_, obj, err := reconcileTestResource(t, r, "hashed")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return obj
+	}
+
+	obj := reconcile()
+	if obj.Annotations[SpecHashAnnotation] == "" {
+		t.Fatal("expected the spec hash annotation to be recorded")
+	}
+
+	obj = reconcile()
+	if upserts != 1 {
+		t.Fatalf("expected unchanged spec to be skipped, got %d upserts", upserts)
+	}
+
+	obj.Spec.Message = "changed"
+	obj.Generation = 2
+	if err := r.Update(gocontext.Background(), obj); err != nil {
+		t.Fatalf("failed to update spec: %v", err)
+	}
+	reconcile()
+	if upserts != 2 {
+		t.Fatalf("expected changed spec to be upserted, got %d upserts", upserts)
+	}
+
+	obj = reconcile()
+	obj.Annotations[ForceResyncAnnotation] = "true"
+	if err := r.Update(gocontext.Background(), obj); err != nil {
+		t.Fatalf("failed to add force annotation: %v", err)
+	}
+	obj = reconcile()
+	if upserts != 3 {
+		t.Fatalf("expected force annotation to bypass the spec hash, got %d upserts", upserts)
+	}
+	if _, ok := obj.Annotations[ForceResyncAnnotation]; ok {
+		t.Error("expected force annotation to be removed after the upsert")
+	}
+
+	r.forcedResyncs.add(types.NamespacedName{Namespace: "default", Name: "hashed"})
+	reconcile()
+	reconcile()
+	if upserts != 4 {
+		t.Errorf("expected a forced resync to upsert once, got %d upserts", upserts)
+	}
+}
+
+func TestReconcileSkippedUpsertKeepsDrifted(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("drifted"))
+	r.SkipUnchangedSpec = true
+	r.DriftMode = DriftModeReport
+	r.DriftCheckFunc = func(context.Context, *testResource) (bool, error) { return true, nil }
+
+	if _, _, err := reconcileTestResource(t, r, "drifted"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.checkDrift(gocontext.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, obj, err := reconcileTestResource(t, r, "drifted")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := k8smeta.FindStatusCondition(obj.Status.Conditions, DriftedConditionType); c == nil || c.Status != metav1.ConditionTrue {
+		t.Errorf("expected Drifted=True to be kept when the upsert is skipped, got %+v", c)
+	}
+}
+
+func TestReconcileClearsForcedResyncWhenSkipped(t *testing.T) {
+	obj := newTestResource("paused")
+	obj.Annotations = map[string]string{DefaultPauseAnnotation: "true"}
+
+	r, _ := newTestReconciler(t, obj)
+	r.SkipUnchangedSpec = true
+
+	key := types.NamespacedName{Namespace: "default", Name: "paused"}
+	r.forcedResyncs.add(key)
+	if _, _, err := reconcileTestResource(t, r, "paused"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.forcedResyncs.has(key) {
+		t.Error("expected the forced resync to be cleared when the reconcile stops early")
+	}
+}
+
+func TestReconcileKeepsForcedResyncOnFailure(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("failing"))
+	r.SkipUnchangedSpec = true
+	r.OnUpsertFunc = func(context.Context, *testResource) error { return errors.New("connection refused") }
+
+	key := types.NamespacedName{Namespace: "default", Name: "failing"}
+	r.forcedResyncs.add(key)
+	if _, _, err := reconcileTestResource(t, r, "failing"); err == nil {
+		t.Fatal("expected the upsert error to be returned")
+	}
+	if !r.forcedResyncs.has(key) {
+		t.Error("expected the forced resync to be kept for the retry")
+	}
+}
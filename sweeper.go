@@ -0,0 +1,138 @@
+package kopper
+
+import (
+	gocontext "context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const defaultOrphanSweepInterval = time.Hour
+
+// ListPersistedIDsFunc returns the UIDs of all resources of the reconciled kind
+// that are persisted in the database.
+type ListPersistedIDsFunc func(context.Context) ([]string, error)
+
+// OrphanSweeperOptions configures the periodic cleanup of database records
+// whose resource no longer exists in the cluster, e.g. after a resource was
+// force-deleted by stripping its finalizer.
+//
+// Live resources are listed from the cache. IDs missing from it are checked against
+// the API server, so resources outside a cache scoped by ManagerOptions are kept.
+// Orphans are deleted with OnDeleteFunc, as only their UID is known.
+// The sweeper can't be used by reconcilers that only set OnDeleteObjectFunc.
+type OrphanSweeperOptions struct {
+	ListPersistedIDs ListPersistedIDsFunc
+
+	// Interval between sweeps. Defaults to 1 hour.
+	Interval time.Duration
+
+	// DryRun only logs the orphaned IDs without calling OnDeleteFunc.
+	DryRun bool
+}
+
+// SweepOrphans calls OnDeleteFunc for every persisted ID that has no matching
+// resource in the cluster and returns the orphaned IDs.
+func (r *Reconciler[T, PT]) SweepOrphans(ctx gocontext.Context) ([]string, error) {
+	if r.OrphanSweeper == nil || r.OrphanSweeper.ListPersistedIDs == nil {
+		return nil, fmt.Errorf("orphan sweeper is not configured for %s", r.gvk.Kind)
+	}
+
+	// list the persisted ids first, so resources upserted in between are in the live list
+	var persisted []string
+	err := r.runCallback(ctx, nil, func(ctx context.Context) (err error) {
+		persisted, err = r.OrphanSweeper.ListPersistedIDs(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persisted %s ids: %w", r.gvk.Kind, err)
+	}
+
+	list, err := r.listResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	live := sets.New[string]()
+	for _, item := range list.Items {
+		live.Insert(string(item.GetUID()))
+	}
+	missing := slices.DeleteFunc(persisted, live.Has)
+
+	if len(missing) > 0 && r.apiReader != nil {
+		// the cache may be limited by ManagerOptions.Namespaces and LabelSelector,
+		// so the missing ids are checked against every resource in the API server
+		all := &metav1.PartialObjectMetadataList{}
+		all.SetGroupVersionKind(r.gvk.GroupVersion().WithKind(r.gvk.Kind + "List"))
+		if err := r.apiReader.List(ctx, all); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", r.gvk.Kind, err)
+		}
+		for _, item := range all.Items {
+			live.Insert(string(item.GetUID()))
+		}
+		missing = slices.DeleteFunc(missing, live.Has)
+	}
+
+	var orphans []string
+	for _, id := range missing {
+
+		// the resource is gone, only its UID is known
+		orphan := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{UID: types.UID(id)}}
+		log := r.objectLogger(ctx, fmt.Sprintf("%s[%s]", r.gvk.Kind, id), orphan)
+
+		orphans = append(orphans, id)
+		if r.OrphanSweeper.DryRun {
+			log.Info(0, "dry run, skipping delete of orphaned resource")
+			continue
+		}
+
+		log.Info(2, "deleting orphaned resource")
+		started := time.Now()
+		err := r.runCallback(ctx, orphan, func(ctx context.Context) error {
+			return r.OnDeleteFunc(ctx, id)
+		})
+		recordCallback(r.gvk, metricsActionSweep, started, err)
+		if err != nil {
+			log.WithAction(metricsActionSweep, started).WithError(err).Error("failed to delete orphaned resource")
+		}
+	}
+
+	return orphans, nil
+}
+
+// addOrphanSweeper registers the periodic sweep with the manager.
+// It only runs on the leader.
+func (r *Reconciler[T, PT]) addOrphanSweeper(mgr ctrl.Manager) error {
+	if r.OrphanSweeper == nil {
+		return nil
+	}
+
+	if r.OrphanSweeper.ListPersistedIDs == nil {
+		return fmt.Errorf("field OrphanSweeper.ListPersistedIDs cannot be empty")
+	}
+	if r.OnDeleteFunc == nil {
+		return fmt.Errorf("field OnDeleteFunc is required by the orphan sweeper")
+	}
+
+	interval := r.OrphanSweeper.Interval
+	if interval <= 0 {
+		interval = defaultOrphanSweepInterval
+	}
+
+	return mgr.Add(manager.RunnableFunc(func(ctx gocontext.Context) error {
+		wait.UntilWithContext(ctx, func(ctx gocontext.Context) {
+			if _, err := r.SweepOrphans(ctx); err != nil {
+				r.kindLogger(ctx).WithError(err).Error("failed to sweep orphaned resources")
+			}
+		}, interval)
+		return nil
+	}))
+}
@@ -0,0 +1,133 @@
+package kopper
+
+import (
+	gocontext "context"
+	"slices"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSweepOrphans(t *testing.T) {
+	tests := []struct {
+		name            string
+		dryRun          bool
+		expectedDeletes []string
+	}{
+		{"deletes orphans", false, []string{"orphan-uid"}},
+		{"dry run", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestReconciler(t, newTestResource("live"))
+
+			var deleted []string
+			r.OnDeleteFunc = func(_ context.Context, id string) error {
+				deleted = append(deleted, id)
+				return nil
+			}
+			r.OrphanSweeper = &OrphanSweeperOptions{
+				DryRun: tt.dryRun,
+				ListPersistedIDs: func(context.Context) ([]string, error) {
+					return []string{"live-uid", "orphan-uid"}, nil
+				},
+			}
+
+			orphans, err := r.SweepOrphans(gocontext.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(orphans, []string{"orphan-uid"}) {
+				t.Errorf("expected orphans [orphan-uid], got %v", orphans)
+			}
+			if !slices.Equal(deleted, tt.expectedDeletes) {
+				t.Errorf("expected deletes %v, got %v", tt.expectedDeletes, deleted)
+			}
+		})
+	}
+}
+
+func TestSweepOrphansNotConfigured(t *testing.T) {
+	r, _ := newTestReconciler(t)
+	if _, err := r.SweepOrphans(gocontext.Background()); err == nil {
+		t.Error("expected an error when the orphan sweeper is not configured")
+	}
+}
+
+func TestSweepOrphansKeepsResourcesCreatedDuringSweep(t *testing.T) {
+	r, _ := newTestReconciler(t)
+
+	var deleted []string
+	r.OnDeleteFunc = func(_ context.Context, id string) error {
+		deleted = append(deleted, id)
+		return nil
+	}
+	r.OrphanSweeper = &OrphanSweeperOptions{
+		ListPersistedIDs: func(ctx context.Context) ([]string, error) {
+			// the resource is created and upserted while the sweep runs
+			if err := r.Create(ctx, newTestResource("fresh")); err != nil {
+				return nil, err
+			}
+			return []string{"fresh-uid"}, nil
+		},
+	}
+
+	orphans, err := r.SweepOrphans(gocontext.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(orphans) != 0 || len(deleted) != 0 {
+		t.Errorf("expected the new resource to be kept, got orphans %v and deletes %v", orphans, deleted)
+	}
+}
+
+func TestSweepOrphansListsFromCache(t *testing.T) {
+	r, _ := newTestReconciler(t)
+	r.cache = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(newTestResource("cached")).Build()
+
+	var deleted []string
+	r.OnDeleteFunc = func(_ context.Context, id string) error {
+		deleted = append(deleted, id)
+		return nil
+	}
+	r.OrphanSweeper = &OrphanSweeperOptions{
+		ListPersistedIDs: func(context.Context) ([]string, error) {
+			return []string{"cached-uid"}, nil
+		},
+	}
+
+	orphans, err := r.SweepOrphans(gocontext.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(orphans) != 0 || len(deleted) != 0 {
+		t.Errorf("expected the cached resource to be kept, got orphans %v and deletes %v", orphans, deleted)
+	}
+}
+
+func TestSweepOrphansKeepsResourcesOutsideTheCache(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestResource("uncached"))
+	r.cache = fake.NewClientBuilder().WithScheme(r.Scheme).Build()
+	r.apiReader = r.Client
+
+	var deleted []string
+	r.OnDeleteFunc = func(_ context.Context, id string) error {
+		deleted = append(deleted, id)
+		return nil
+	}
+	r.OrphanSweeper = &OrphanSweeperOptions{
+		ListPersistedIDs: func(context.Context) ([]string, error) {
+			return []string{"uncached-uid", "orphan-uid"}, nil
+		},
+	}
+
+	orphans, err := r.SweepOrphans(gocontext.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(orphans, []string{"orphan-uid"}) || !slices.Equal(deleted, []string{"orphan-uid"}) {
+		t.Errorf("expected only orphan-uid to be deleted, got orphans %v and deletes %v", orphans, deleted)
+	}
+}
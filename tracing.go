@@ -0,0 +1,87 @@
+package kopper
+
+import (
+	gocontext "context"
+
+	"github.com/flanksource/duty/context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const tracerName = "github.com/flanksource/kopper"
+
+const (
+	spanReconcile       = "kopper.Reconcile"
+	spanConvert         = "kopper.Convert"
+	spanUpdateFinalizer = "kopper.UpdateFinalizer"
+	spanUpsert          = "kopper.Upsert"
+	spanDelete          = "kopper.Delete"
+	spanConflict        = "kopper.Conflict"
+	spanUpdateStatus    = "kopper.UpdateStatus"
+	spanDriftCheck      = "kopper.DriftCheck"
+)
+
+func (r *Reconciler[T, PT]) tracer() trace.Tracer {
+	if r.Tracer != nil {
+		return r.Tracer
+	}
+	return otel.Tracer(tracerName)
+}
+
+// startSpan starts a child span of the span in ctx, tagged with the identity of obj.
+func (r *Reconciler[T, PT]) startSpan(ctx gocontext.Context, name string, obj metav1.Object) (gocontext.Context, trace.Span) {
+	return r.tracer().Start(ctx, name, trace.WithAttributes(spanAttributes(r.gvk, obj)...))
+}
+
+func spanAttributes(gvk schema.GroupVersionKind, obj metav1.Object) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("k8s.gvk", gvk.String()),
+		attribute.String("k8s.namespace", obj.GetNamespace()),
+		attribute.String("k8s.name", obj.GetName()),
+		attribute.String("k8s.uid", string(obj.GetUID())),
+		attribute.Int64("k8s.generation", obj.GetGeneration()),
+	}
+}
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// callbackContext returns the duty context handed to callbacks,
+// carrying the span active in ctx and the identity of obj, and cancelled along with ctx.
+func (r *Reconciler[T, PT]) callbackContext(ctx gocontext.Context, obj client.Object) context.Context {
+	values := trace.ContextWithSpan(r.DutyContext, trace.SpanFromContext(ctx))
+	dutyCtx := withGoContext(r.DutyContext, &mergedContext{Context: ctx, values: values})
+	return withObjectIdentity(dutyCtx, r.objectIdentity(ctx, obj))
+}
+
+// mergedContext is cancelled along with its Context, e.g. on reconcile timeouts,
+// and looks up values in values first, e.g. those of the duty context.
+type mergedContext struct {
+	gocontext.Context
+	values gocontext.Context
+}
+
+func (c *mergedContext) Value(key any) any {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// withGoContext returns a copy of dutyCtx backed by ctx, keeping its logger and tracer.
+func withGoContext(dutyCtx context.Context, ctx gocontext.Context) context.Context {
+	cloned := dutyCtx.Context.Clone()
+	cloned.Context = ctx
+	return context.Context{Context: cloned}
+}
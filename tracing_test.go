@@ -0,0 +1,89 @@
+package kopper
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestReconcileSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	obj := newTestResource("traced")
+	obj.Finalizers = nil
+
+	r, _ := newTestReconciler(t, obj)
+	r.Tracer = provider.Tracer(tracerName)
+
+	var callbackSpan trace.SpanContext
+	r.OnUpsertFunc = func(ctx context.Context, _ *testResource) error {
+		callbackSpan = trace.SpanFromContext(ctx).SpanContext()
+		return nil
+	}
+
+	if _, _, err := reconcileTestResource(t, r, "traced"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	byName := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+
+	for _, name := range []string{spanReconcile, spanConvert, spanUpdateFinalizer, spanUpsert, spanUpdateStatus} {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("expected span %s, got %v", name, spans)
+		}
+	}
+
+	root := byName[spanReconcile]
+	for _, name := range []string{spanConvert, spanUpdateFinalizer, spanUpsert, spanUpdateStatus} {
+		if parent := byName[name].Parent.SpanID(); parent != root.SpanContext.SpanID() {
+			t.Errorf("expected %s to be a child of %s", name, spanReconcile)
+		}
+	}
+
+	upsert := byName[spanUpsert]
+	if callbackSpan.SpanID() != upsert.SpanContext.SpanID() {
+		t.Errorf("expected OnUpsertFunc context to carry the %s span", spanUpsert)
+	}
+
+	attrs := attribute.NewSet(upsert.Attributes...)
+	if v, _ := attrs.Value("k8s.uid"); v.AsString() != "traced-uid" {
+		t.Errorf("expected k8s.uid attribute, got %v", upsert.Attributes)
+	}
+	if v, _ := attrs.Value("k8s.generation"); v.AsInt64() != 1 {
+		t.Errorf("expected k8s.generation attribute, got %v", upsert.Attributes)
+	}
+}
+
+func TestCallbackContextCancellation(t *testing.T) {
+	obj := newTestResource("timeout")
+	r, _ := newTestReconciler(t, obj)
+
+	callbackErrs := make(chan error, 1)
+	r.OnUpsertFunc = func(ctx context.Context, _ *testResource) error {
+		<-ctx.Done()
+		callbackErrs <- ctx.Err()
+		return ctx.Err()
+	}
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _ = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "timeout"}})
+
+	if callbackErr := <-callbackErrs; !errors.Is(callbackErr, gocontext.DeadlineExceeded) {
+		t.Errorf("expected the callback context to be cancelled with the reconcile, got %v", callbackErr)
+	}
+}
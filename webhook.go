@@ -0,0 +1,193 @@
+package kopper
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/flanksource/duty/context"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ValidateFunc is called by the validating webhook before a resource is admitted.
+// Returning an error rejects the resource with the error message.
+type ValidateFunc[PT client.Object] func(context.Context, PT) error
+
+// DefaultFunc fills in the defaults of a resource.
+// It is called by the mutating webhook and in Reconcile before OnUpsertFunc,
+// so resources admitted before the webhook existed are defaulted the same way.
+type DefaultFunc[PT client.Object] func(context.Context, PT) error
+
+// MutatePath returns the path the mutating webhook of gvk is served on,
+// following the controller-runtime convention, e.g. /mutate-example-com-v1-widget.
+func MutatePath(gvk schema.GroupVersionKind) string {
+	return "/mutate-" + strings.ReplaceAll(gvk.Group, ".", "-") + "-" + gvk.Version + "-" + strings.ToLower(gvk.Kind)
+}
+
+// ValidatePath returns the path the validating webhook of gvk is served on,
+// following the controller-runtime convention, e.g. /validate-example-com-v1-widget.
+func ValidatePath(gvk schema.GroupVersionKind) string {
+	return "/validate-" + strings.ReplaceAll(gvk.Group, ".", "-") + "-" + gvk.Version + "-" + strings.ToLower(gvk.Kind)
+}
+
+// validatingWebhook admits resources that convert to PT and pass ValidateFunc.
+type validatingWebhook[T any, PT interface {
+	*T
+	client.Object
+}] struct {
+	r *Reconciler[T, PT]
+}
+
+func (w *validatingWebhook[T, PT]) Handle(ctx gocontext.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	raw := &unstructured.Unstructured{}
+	if err := raw.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// finalizers must be removable from resources that no longer validate, e.g. after the rules were tightened
+	if req.Operation == admissionv1.Update {
+		if !raw.GetDeletionTimestamp().IsZero() {
+			return admission.Allowed("")
+		}
+		if metadataOnly, err := isMetadataOnlyUpdate(req, raw); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		} else if metadataOnly {
+			return admission.Allowed("")
+		}
+	}
+
+	obj := PT(new(T))
+	if err := fromUnstructured(raw.Object, obj); err != nil {
+		return admission.Denied(malformedResourceMessage(err))
+	}
+
+	if w.r.ValidateFunc != nil {
+		err := w.r.runCallback(ctx, obj, func(ctx context.Context) error {
+			return w.r.ValidateFunc(ctx, obj)
+		})
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+// isMetadataOnlyUpdate reports whether an update leaves everything but the metadata and status of raw unchanged.
+func isMetadataOnlyUpdate(req admission.Request, raw *unstructured.Unstructured) (bool, error) {
+	var old map[string]any
+	if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+		return false, err
+	}
+
+	updated := raw.DeepCopy().Object
+	for _, u := range []map[string]any{old, updated} {
+		delete(u, "metadata")
+		delete(u, "status")
+	}
+	return equality.Semantic.DeepEqual(old, updated), nil
+}
+
+// defaultingWebhook patches resources with the defaults set by DefaultFunc.
+type defaultingWebhook[T any, PT interface {
+	*T
+	client.Object
+}] struct {
+	r *Reconciler[T, PT]
+}
+
+func (w *defaultingWebhook[T, PT]) Handle(ctx gocontext.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	raw := &unstructured.Unstructured{}
+	if err := raw.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	obj := PT(new(T))
+	if err := fromUnstructured(raw.Object, obj); err != nil {
+		return admission.Denied(malformedResourceMessage(err))
+	}
+
+	original := obj.DeepCopyObject()
+	err := w.r.runCallback(ctx, obj, func(ctx context.Context) error {
+		return w.r.DefaultFunc(ctx, obj)
+	})
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	if equality.Semantic.DeepEqual(original, obj) {
+		return admission.Allowed("")
+	}
+
+	marshalledOriginal, err := json.Marshal(original)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	marshalled, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	// Marshalling PT drops unknown fields and adds empty ones;
+	// only keep the operations that come from the defaults.
+	roundTrip, err := jsonpatch.CreatePatch(req.Object.Raw, marshalledOriginal)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	resp := admission.PatchResponseFromRaw(req.Object.Raw, marshalled)
+	resp.Patches = slices.DeleteFunc(resp.Patches, func(p jsonpatch.JsonPatchOperation) bool {
+		return slices.ContainsFunc(roundTrip, func(o jsonpatch.JsonPatchOperation) bool {
+			return equality.Semantic.DeepEqual(o, p)
+		})
+	})
+	if len(resp.Patches) == 0 {
+		resp.PatchType = nil
+	}
+	return resp
+}
+
+// applyDefaults runs DefaultFunc, if set, on resources that were not defaulted on admission.
+func (r *Reconciler[T, PT]) applyDefaults(ctx context.Context, obj PT) error {
+	if r.DefaultFunc == nil {
+		return nil
+	}
+
+	if err := r.DefaultFunc(ctx, obj); err != nil {
+		return fmt.Errorf("failed to apply defaults: %w", err)
+	}
+	return nil
+}
+
+// addWebhooks registers the admission webhooks of the reconciled kind with the manager's webhook server.
+func (r *Reconciler[T, PT]) addWebhooks(mgr ctrl.Manager) {
+	if r.ValidatingWebhook {
+		mgr.GetWebhookServer().Register(ValidatePath(r.gvk), &webhook.Admission{
+			Handler: &validatingWebhook[T, PT]{r: r},
+		})
+	}
+
+	if r.DefaultFunc != nil && r.DefaultingWebhook {
+		mgr.GetWebhookServer().Register(MutatePath(r.gvk), &webhook.Admission{
+			Handler: &defaultingWebhook[T, PT]{r: r},
+		})
+	}
+}
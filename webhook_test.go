@@ -0,0 +1,123 @@
+package kopper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/duty/context"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func admissionRequest(operation admissionv1.Operation, raw string) admission.Request {
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: operation,
+		Object:    runtime.RawExtension{Raw: []byte(raw)},
+	}}
+}
+
+func TestValidatingWebhook(t *testing.T) {
+	r, _ := newTestReconciler(t)
+	r.ValidateFunc = func(_ context.Context, obj *testResource) error {
+		if obj.Spec.Message == "" {
+			return errors.New("spec.message is required")
+		}
+		return nil
+	}
+	w := &validatingWebhook[testResource, *testResource]{r: r}
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		old       string
+		raw       string
+		allowed   bool
+		message   string
+	}{
+		{"valid", admissionv1.Create, ``, `{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{"message":"hello"}}`, true, ""},
+		{"malformed", admissionv1.Update, `{"spec":{"message":"hello"}}`, `{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{"message":1}}`, false, "Resource spec does not match expected schema"},
+		{"invalid", admissionv1.Create, ``, `{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{}}`, false, "spec.message is required"},
+		{"invalid update", admissionv1.Update,
+			`{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{"message":"hello"}}`,
+			`{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{}}`, false, "spec.message is required"},
+		{"finalizer removal from an invalid resource", admissionv1.Update,
+			`{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a","finalizers":["test.kopper.io"]},"spec":{}}`,
+			`{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{}}`, true, ""},
+		{"update of a deleting invalid resource", admissionv1.Update,
+			`{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{"message":1}}`,
+			`{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a","deletionTimestamp":"2024-01-01T00:00:00Z"},"spec":{"message":1}}`, true, ""},
+		{"delete", admissionv1.Delete, ``, ``, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := admissionRequest(tt.operation, tt.raw)
+			req.OldObject = runtime.RawExtension{Raw: []byte(tt.old)}
+			resp := w.Handle(t.Context(), req)
+			if resp.Allowed != tt.allowed {
+				t.Fatalf("expected allowed=%v, got %+v", tt.allowed, resp.Result)
+			}
+			if tt.message != "" && !strings.Contains(resp.Result.Message, tt.message) {
+				t.Errorf("expected message containing %q, got %q", tt.message, resp.Result.Message)
+			}
+		})
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	if path := ValidatePath(testGVK); path != "/validate-test-kopper-io-v1-testresource" {
+		t.Errorf("unexpected path %q", path)
+	}
+}
+
+func TestDefaultingWebhook(t *testing.T) {
+	r, _ := newTestReconciler(t)
+	r.DefaultFunc = func(_ context.Context, obj *testResource) error {
+		if obj.Spec.Message == "" {
+			obj.Spec.Message = "default"
+		}
+		return nil
+	}
+	w := &defaultingWebhook[testResource, *testResource]{r: r}
+
+	resp := w.Handle(t.Context(), admissionRequest(admissionv1.Create,
+		`{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{"unknown":"kept"}}`))
+	if !resp.Allowed {
+		t.Fatalf("expected resource to be allowed, got %+v", resp.Result)
+	}
+	if len(resp.Patches) != 1 || resp.Patches[0].Operation != "add" || resp.Patches[0].Path != "/spec/message" || resp.Patches[0].Value != "default" {
+		t.Errorf("expected a single patch adding the default message, got %+v", resp.Patches)
+	}
+
+	resp = w.Handle(t.Context(), admissionRequest(admissionv1.Create,
+		`{"apiVersion":"test.kopper.io/v1","kind":"TestResource","metadata":{"name":"a"},"spec":{"message":"set"}}`))
+	if !resp.Allowed || len(resp.Patches) != 0 {
+		t.Errorf("expected no patches for a defaulted resource, got %+v", resp.Patches)
+	}
+}
+
+func TestReconcileAppliesDefaults(t *testing.T) {
+	obj := newTestResource("defaulted")
+	obj.Spec.Message = ""
+
+	r, _ := newTestReconciler(t, obj)
+	r.DefaultFunc = func(_ context.Context, obj *testResource) error {
+		obj.Spec.Message = "default"
+		return nil
+	}
+
+	var upserted string
+	r.OnUpsertFunc = func(_ context.Context, obj *testResource) error {
+		upserted = obj.Spec.Message
+		return nil
+	}
+
+	if _, _, err := reconcileTestResource(t, r, "defaulted"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if upserted != "default" {
+		t.Errorf("expected OnUpsertFunc to receive the defaulted spec, got %q", upserted)
+	}
+}